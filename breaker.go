@@ -0,0 +1,210 @@
+/** ****************************************************************************************************************** **
+	Circuit breaker
+
+	When serviceworks is down there's no point in every worker hammering it and waiting for timeouts.
+	After enough 5xx or network errors the breaker opens and calls fail right away with ErrCircuitOpen.
+	Once OpenFor has passed a test call is let through (half open), if it works we close again.
+
+** ****************************************************************************************************************** **/
+
+package serviceworks
+
+import (
+	"github.com/pkg/errors"
+
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- CONSTS ----------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+type CircuitState int
+
+const (
+	CircuitClosed 		CircuitState = iota // everything is normal
+	CircuitOpen 		// failing fast
+	CircuitHalfOpen 	// letting a test call through
+)
+
+func (this CircuitState) String () string {
+	switch this {
+	case CircuitClosed: return "closed"
+	case CircuitOpen: return "open"
+	case CircuitHalfOpen: return "half-open"
+	}
+	return "unknown"
+}
+
+var ErrCircuitOpen = errors.New("Circuit breaker is open, serviceworks is failing")
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- STRUCTS ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+type BreakerConfig struct {
+	ConsecutiveFailures int // opens after this many failures in a row, defaults to 5
+	FailureRatio float64 // also opens when this share of the last Window calls failed, 0 turns this off
+	Window int // how many recent calls we use for the ratio, defaults to 20
+	OpenFor time.Duration // how long we fail fast before trying again, defaults to 30 seconds
+	HalfOpenSuccesses int // how many test calls need to work before we close again, defaults to 1
+}
+
+type breaker struct {
+	cfg BreakerConfig
+
+	lock sync.Mutex
+	state CircuitState
+	consecutive int // failures in a row
+	recent []bool // ring of the last Window results, true for failures
+	next int // where the next result goes in recent
+	openedAt time.Time
+	probing bool // true while a half open test call is out
+	successes int // test calls that worked while half open
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- PRIVATE ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+func newBreaker (cfg BreakerConfig) *breaker {
+	if cfg.ConsecutiveFailures <= 0 { cfg.ConsecutiveFailures = 5 }
+	if cfg.Window <= 0 { cfg.Window = 20 }
+	if cfg.OpenFor <= 0 { cfg.OpenFor = time.Second * 30 }
+	if cfg.HalfOpenSuccesses <= 0 { cfg.HalfOpenSuccesses = 1 }
+
+	return &breaker { cfg: cfg, recent: make([]bool, 0, cfg.Window) }
+}
+
+// returns the current state, moving from open to half open if it's time, expects the lock to be held
+func (this *breaker) current () CircuitState {
+	if this.state == CircuitOpen && time.Since (this.openedAt) >= this.cfg.OpenFor {
+		this.state = CircuitHalfOpen
+		this.probing = false
+		this.successes = 0
+	}
+	return this.state
+}
+
+// returns an error if the call isn't allowed through
+func (this *breaker) allow () error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	switch this.current() {
+	case CircuitOpen:
+		return errors.WithStack (ErrCircuitOpen)
+
+	case CircuitHalfOpen:
+		if this.probing { return errors.WithStack (ErrCircuitOpen) } // only one test call at a time
+		this.probing = true
+	}
+	return nil
+}
+
+// expects the lock to be held
+func (this *breaker) open () {
+	this.state = CircuitOpen
+	this.openedAt = time.Now()
+	this.consecutive = 0
+	this.recent = this.recent[:0]
+	this.next = 0
+}
+
+// records the result of a call we allowed through
+func (this *breaker) record (failed bool) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if this.state == CircuitHalfOpen {
+		this.probing = false
+
+		if failed {
+			this.open() // still broken
+			return
+		}
+
+		this.successes++
+		if this.successes >= this.cfg.HalfOpenSuccesses {
+			this.state = CircuitClosed // we're back
+		}
+		return
+	}
+
+	// keep track of the recent results
+	if len(this.recent) < this.cfg.Window {
+		this.recent = append (this.recent, failed)
+	} else {
+		this.recent[this.next] = failed
+	}
+	this.next = (this.next + 1) % this.cfg.Window
+
+	if failed == false {
+		this.consecutive = 0
+		return
+	}
+
+	this.consecutive++
+	if this.consecutive >= this.cfg.ConsecutiveFailures {
+		this.open()
+		return
+	}
+
+	if this.cfg.FailureRatio > 0 && len(this.recent) == this.cfg.Window {
+		failures := 0
+		for _, f := range this.recent {
+			if f { failures++ }
+		}
+		if float64(failures) / float64(len(this.recent)) >= this.cfg.FailureRatio { this.open() }
+	}
+}
+
+// the call was canceled by the caller, so it doesn't tell us anything
+func (this *breaker) release () {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	this.probing = false
+}
+
+// returns true if this result counts against serviceworks, 5xx and network errors
+func breakerFailure (resp *Response, err error) bool {
+	if err != nil { return IsRetryable (err) }
+	return resp != nil && resp.HTTPStatus > 499 && resp.HTTPStatus != http.StatusNotImplemented
+}
+
+func (this *breaker) middleware (next Handler) Handler {
+	return func (ctx context.Context, req *Request) (*Response, error) {
+		if err := this.allow(); err != nil { return nil, err }
+
+		resp, err := next (ctx, req)
+		if ctx.Err() != nil {
+			this.release() // the caller gave up, that's on us not serviceworks
+		} else {
+			this.record (breakerFailure (resp, err))
+		}
+		return resp, err
+	}
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- FUNCTIONS -------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// fails fast while serviceworks is down
+func WithCircuitBreaker (cfg BreakerConfig) Option {
+	return func (o *options) { o.breaker = &cfg }
+}
+
+// returns the state of the circuit breaker for health checks, always closed if we don't have one
+func (this *ServiceWorks) CircuitState () CircuitState {
+	if this.breaker == nil { return CircuitClosed }
+
+	this.breaker.lock.Lock()
+	defer this.breaker.lock.Unlock()
+
+	return this.breaker.current()
+}
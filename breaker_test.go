@@ -0,0 +1,118 @@
+
+package serviceworks 
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"testing"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+)
+
+// opens after failures in a row, then closes once a test call works
+func TestBreaker1 (t *testing.T) {
+	failing := int32(1)
+	calls := int32(0)
+	server := httptest.NewServer (http.HandlerFunc (func (w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32 (&calls, 1)
+		if atomic.LoadInt32 (&failing) == 1 {
+			w.WriteHeader (http.StatusBadGateway)
+			return 
+		}
+		w.Write ([]byte(`{"ApiStatus":{"Status":1},"Data":[]}`))
+	}))
+	defer server.Close()
+
+	sw := New (WithBaseURL (server.URL), WithCircuitBreaker (BreakerConfig { ConsecutiveFailures: 3, OpenFor: time.Millisecond * 50 }))
+	assert.Equal (t, CircuitClosed, sw.CircuitState())
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	for i := 0; i < 3; i++ {
+		_, err := sw.JobsListTimeRanges (ctx, "token")
+		assert.Equal (t, false, errors.Is (err, ErrCircuitOpen))
+	}
+	assert.Equal (t, CircuitOpen, sw.CircuitState())
+
+	// now we fail fast
+	_, err := sw.JobsListTimeRanges (ctx, "token")
+	assert.Equal (t, true, errors.Is (err, ErrCircuitOpen))
+	assert.Equal (t, true, IsRetryable (err))
+	assert.Equal (t, int32(3), atomic.LoadInt32 (&calls))
+
+	// after a bit we let a test call through, which fails and opens it again
+	time.Sleep (time.Millisecond * 60)
+	assert.Equal (t, CircuitHalfOpen, sw.CircuitState())
+
+	_, err = sw.JobsListTimeRanges (ctx, "token")
+	assert.Equal (t, false, errors.Is (err, ErrCircuitOpen))
+	assert.Equal (t, CircuitOpen, sw.CircuitState())
+
+	// this time it works
+	atomic.StoreInt32 (&failing, 0)
+	time.Sleep (time.Millisecond * 60)
+
+	_, err = sw.JobsListTimeRanges (ctx, "token")
+	if err != nil { t.Fatal (err) }
+	assert.Equal (t, CircuitClosed, sw.CircuitState())
+	assert.Equal (t, "closed", sw.CircuitState().String())
+}
+
+// opens when too many of the recent calls failed
+func TestBreaker2 (t *testing.T) {
+	b := newBreaker (BreakerConfig { ConsecutiveFailures: 100, FailureRatio: 0.5, Window: 4 })
+
+	b.record (true)
+	b.record (false)
+	b.record (false)
+	assert.Equal (t, CircuitClosed, b.current())
+
+	b.record (true) // 2 of 4
+	assert.Equal (t, CircuitOpen, b.current())
+
+	// client errors don't count
+	assert.Equal (t, false, breakerFailure (&Response { HTTPStatus: http.StatusBadRequest }, nil))
+	assert.Equal (t, true, breakerFailure (&Response { HTTPStatus: http.StatusServiceUnavailable }, nil))
+}
+
+// our client timing out counts, the caller giving up doesn't
+func TestBreaker3 (t *testing.T) {
+	server := httptest.NewServer (http.HandlerFunc (func (w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After (time.Millisecond * 300): // longer than our client will wait
+		}
+	}))
+	defer server.Close()
+
+	sw := New (WithBaseURL (server.URL), WithTimeout (time.Millisecond * 50), WithCircuitBreaker (BreakerConfig { ConsecutiveFailures: 2, OpenFor: time.Minute }))
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	// the caller's own deadline
+	short, cancelShort := context.WithTimeout (ctx, time.Millisecond * 20)
+	defer cancelShort()
+
+	for i := 0; i < 3; i++ {
+		_, err := sw.JobsListTimeRanges (short, "token")
+		if err == nil { t.Fatal ("we were expecting an error") }
+	}
+	assert.Equal (t, CircuitClosed, sw.CircuitState())
+
+	// now the client's timeout
+	for i := 0; i < 2; i++ {
+		_, err := sw.JobsListTimeRanges (ctx, "token")
+		assert.Equal (t, true, IsRetryable (err))
+		assert.Equal (t, false, errors.Is (err, ErrCircuitOpen))
+	}
+	assert.Equal (t, CircuitOpen, sw.CircuitState())
+
+	_, err := sw.JobsListTimeRanges (ctx, "token")
+	assert.Equal (t, true, errors.Is (err, ErrCircuitOpen))
+}
@@ -14,6 +14,7 @@ import (
     
     "net/http"
     "context"
+    "encoding/json"
 )
 
   //-----------------------------------------------------------------------------------------------------------------------//
@@ -24,6 +25,21 @@ type Employee struct {
     EmployeeID int
     FirstName, LastName, Address, Zip, CityName, State, Phone, Email, UserId, Color string 
     IsTechnician, IsActive bool 
+
+    Extra map[string]json.RawMessage `json:"-"` // fields we don't model, written back out when this is marshaled
+}
+
+// keeps the fields we don't model in Extra
+func (this *Employee) UnmarshalJSON (b []byte) (err error) {
+    type alias Employee // so we don't end up back in here
+    this.Extra, err = decodeWithExtra (b, (*alias)(this))
+    return
+}
+
+// writes the fields from Extra back out, so they survive a json round trip
+func (this Employee) MarshalJSON () ([]byte, error) {
+    type alias Employee
+    return encodeWithExtra (alias(this), this.Extra)
 }
 
   //-----------------------------------------------------------------------------------------------------------------------//
@@ -0,0 +1,120 @@
+/** ****************************************************************************************************************** **
+	Curl commands for failed requests
+
+	When a call fails we can attach a curl command that reproduces it, so it's easy to send to serviceworks support.
+	The Token, Password and ApiKey headers are always masked, and the body follows the same redaction as our errors.
+
+** ****************************************************************************************************************** **/
+
+package serviceworks
+
+import (
+	"github.com/pkg/errors"
+
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- STRUCTS ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// carries the curl command for errors that didn't come from serviceworks, like network errors
+type curlError struct {
+	err error
+	curl string
+}
+
+func (this *curlError) Error () string { return this.err.Error() }
+func (this *curlError) Unwrap () error { return this.err }
+func (this *curlError) Cause () error { return this.err }
+
+// so concurrent calls don't interleave their commands
+type dumpWriter struct {
+	lock sync.Mutex
+	w io.Writer
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- PRIVATE ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// quotes the value for a posix shell
+func shellQuote (val string) string {
+	return "'" + strings.ReplaceAll (val, "'", `'\''`) + "'"
+}
+
+// builds the curl command for this request
+func (this *ServiceWorks) curl (method, link string, header http.Header, body []byte) string {
+	r := this.redactor()
+
+	parts := []string { "curl", "-X", method, shellQuote (fmt.Sprintf ("%s/%s", this.url(), r.link (link))) }
+
+	keys := make([]string, 0, len(header))
+	for key := range header { keys = append (keys, key) }
+	sort.Strings (keys)
+
+	for _, key := range keys {
+		for _, val := range header[key] {
+			if secretHeader (key) { val = redactedValue } // even in debug mode
+			parts = append (parts, "-H", shellQuote (key + ": " + val))
+		}
+	}
+
+	if len(body) > 0 {
+		parts = append (parts, "--data-raw", shellQuote (r.text (string(body))))
+	}
+
+	return strings.Join (parts, " ")
+}
+
+// attaches the curl command to a failed request, and writes it to our dump if we have one
+// returns the error to use, which may have been wrapped
+func (this *ServiceWorks) attachCurl (req *Request, errObj *Error, env *apiStatus, err error) error {
+	if this.curlEnabled == false && this.dump == nil { return err }
+
+	failed := err != nil || errObj != nil || (env != nil && env.Error() != nil)
+	if failed == false { return err }
+
+	cmd := this.curl (req.Method, req.link, req.Header, req.Body)
+
+	if this.dump != nil {
+		this.dump.lock.Lock()
+		fmt.Fprintf (this.dump.w, "# %s failed\n%s\n", req.Endpoint, cmd)
+		this.dump.lock.Unlock()
+	}
+
+	if this.curlEnabled == false { return err }
+
+	if errObj != nil { errObj.curl = cmd }
+	if env != nil { env.curl = cmd }
+	if err != nil { err = &curlError { err: err, curl: cmd } }
+	return err
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- FUNCTIONS -------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// attaches a curl command to the errors from failed requests, get it with CurlCommand
+func WithCurl (enabled bool) Option {
+	return func (o *options) { o.curl = enabled }
+}
+
+// writes a curl command for every failed request to w
+func WithDebugDump (w io.Writer) Option {
+	return func (o *options) { o.dump = w }
+}
+
+// returns the curl command that reproduces the failed request, or empty if there isn't one
+func CurlCommand (err error) string {
+	if apiErr := AsAPIError (err); apiErr != nil && len(apiErr.Curl) > 0 { return apiErr.Curl }
+
+	var cErr *curlError
+	if errors.As (err, &cErr) { return cErr.curl }
+	return ""
+}
@@ -0,0 +1,62 @@
+package serviceworks 
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/BeelineRoutes/service-works/swtest"
+
+	"testing"
+	"context"
+	"bytes"
+	"strings"
+	"time"
+)
+
+// failed calls carry a curl command
+func TestCurl1 (t *testing.T) {
+	server := swtest.NewServer()
+	defer server.Close()
+
+	var dump bytes.Buffer
+	sw := New (WithBaseURL (server.URL), WithCurl (true), WithDebugDump (&dump))
+	token := server.Token()
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	// no first name is a validation error
+	_, err := sw.CreateCustomer (ctx, token, "", "Potter", "harry@hogwarts.edu", "", "4 Privet Dr", "", "05482", "Shelburne", "VT")
+	assert.NotNil (t, err)
+
+	cmd := CurlCommand (err)
+	assert.Equal (t, true, strings.HasPrefix (cmd, "curl -X POST '" + server.URL + "/api/Job/AddEditCustomerDetail'"))
+	assert.Equal (t, true, strings.Contains (cmd, "-H 'Token: REDACTED'"))
+	assert.Equal (t, false, strings.Contains (cmd, token))
+	assert.Equal (t, true, strings.Contains (cmd, "--data-raw '{"))
+	assert.Equal (t, false, strings.Contains (cmd, "harry@hogwarts.edu")) // pii is still masked
+	assert.Equal (t, true, strings.Contains (dump.String(), cmd))
+
+	// http errors have it too
+	err = sw.Do (ctx, "GET", "Job/SomethingNew", nil, token, nil, nil)
+	assert.Equal (t, true, strings.HasPrefix (CurlCommand (err), "curl -X GET"))
+
+	// and nothing for calls that worked
+	dump.Reset()
+	err = sw.Do (ctx, "GET", "Job/GetTimeRange", nil, token, nil, nil)
+	assert.Nil (t, err)
+	assert.Equal (t, 0, dump.Len())
+
+	// network errors
+	bad := New (WithBaseURL ("http://127.0.0.1:1"), WithCurl (true))
+	_, err = bad.SearchCustomers (ctx, token, "potter")
+	assert.NotNil (t, err)
+	assert.Equal (t, true, strings.Contains (CurlCommand (err), "127.0.0.1:1/api/Job/GetCustomerSearch"))
+
+	// off by default
+	plain := New (WithBaseURL (server.URL))
+	err = plain.Do (ctx, "GET", "Job/SomethingNew", nil, token, nil, nil)
+	assert.Equal (t, "", CurlCommand (err))
+}
+
+func TestShellQuote1 (t *testing.T) {
+	assert.Equal (t, `'it'\''s'`, shellQuote ("it's"))
+}
@@ -11,6 +11,7 @@ import (
     "fmt"
     "net/http"
     "context"
+    "encoding/json"
     "net/url"
     "strings"
 )
@@ -49,6 +50,43 @@ type Address struct {
     AddressId, Type int
     AddressLine1, AddressLine2, Zip, City, State, Lat, Long, FirstName, LastName, PrimaryPhone, Email string 
     NotifyEmail, NotifyPrimaryPhone bool 
+
+    Extra map[string]json.RawMessage `json:"-"` // fields we don't model, written back out when this is marshaled
+}
+
+// keeps the fields we don't model in Extra
+func (this *Address) UnmarshalJSON (b []byte) (err error) {
+    type alias Address // so we don't end up back in here
+    this.Extra, err = decodeWithExtra (b, (*alias)(this))
+    return
+}
+
+// writes the fields from Extra back out, so they survive a json round trip
+func (this Address) MarshalJSON () ([]byte, error) {
+    type alias Address
+    return encodeWithExtra (alias(this), this.Extra)
+}
+
+// like addressGet, only the parts we have so there aren't any double spaces
+func (this *Address) human () string {
+    ret := this.AddressLine1
+    if len(this.AddressLine2) > 0 {
+        ret = ret + " " + this.AddressLine2
+    }
+
+    if len(this.City) > 0 {
+        ret = ret + " " + this.City
+    }
+
+    if len(this.State) > 0 {
+        ret = ret + ", " + this.State
+    }
+
+    if len(this.Zip) > 0 {
+        ret = ret + " " + this.Zip
+    }
+
+    return ret 
 }
 
 type Customer struct {
@@ -57,6 +95,21 @@ type Customer struct {
     IsActive bool 
 
     Addresses []Address
+
+    Extra map[string]json.RawMessage `json:"-"` // fields we don't model, written back out when this is marshaled
+}
+
+// keeps the fields we don't model in Extra
+func (this *Customer) UnmarshalJSON (b []byte) (err error) {
+    type alias Customer // so we don't end up back in here
+    this.Extra, err = decodeWithExtra (b, (*alias)(this))
+    return
+}
+
+// writes the fields from Extra back out, so they survive a json round trip
+func (this Customer) MarshalJSON () ([]byte, error) {
+    type alias Customer
+    return encodeWithExtra (alias(this), this.Extra)
 }
 
   //-----------------------------------------------------------------------------------------------------------------------//
@@ -0,0 +1,252 @@
+/** ****************************************************************************************************************** **
+	Typed errors
+
+	Every error response from serviceworks, either an http error or a failed ApiStatus, is returned as an *APIError.
+	It unwraps to one of our sentinel errors, so errors.Is and errors.As work, as does errors.Cause from pkg/errors.
+
+** ****************************************************************************************************************** **/
+
+package serviceworks
+
+import (
+	"github.com/pkg/errors"
+
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- STRUCTS ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+type APIError struct {
+	HTTPStatus int // status code of the http response
+	Status int // ApiStatus.Status, 0 if the response didn't have one
+	Message string // ApiStatus.Message, or the error message from the body
+	Description string // extra detail from the body, if there was any
+	Endpoint string // the call we made, ie Job/SaveSchedule
+	Errors interface{} // the decoded ApiStatus.Errors payload
+	Fields []FieldError // Errors broken out by field, empty if the payload didn't have any
+	Curl string // a command that reproduces the call, only set with WithCurl
+
+	Err error // the sentinel this unwraps to, ErrBadResponse if we don't know anything more specific
+}
+
+func (this *APIError) Error () string {
+	msg := fmt.Sprintf ("%s :: %s :: http %d", this.Err, this.Endpoint, this.HTTPStatus)
+
+	if this.Status != 0 { msg += fmt.Sprintf (" :: status %d", this.Status) }
+	if len(this.Message) > 0 { msg += fmt.Sprintf (" :: message '%s'", this.Message) }
+	if len(this.Description) > 0 { msg += " :: " + this.Description }
+
+	if this.Errors != nil {
+		jstr, _ := json.Marshal (this.Errors)
+		msg += " :: " + string(jstr)
+	}
+	return msg
+}
+
+// for errors.Is and errors.As
+func (this *APIError) Unwrap () error { return this.Err }
+
+// for errors.Cause from pkg/errors
+func (this *APIError) Cause () error { return this.Err }
+
+// an archived job is still a gone resource, so ErrJobGone matches ErrGone as well
+func (this *APIError) Is (target error) bool {
+	return this.Err == ErrJobGone && target == ErrGone
+}
+
+// a single rejected field from a call like AddEditCustomerDetail or CreateNewJob
+type FieldError struct {
+	Field string // the field name as serviceworks sent it, empty if the message isn't tied to one
+	Message string 
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- PRIVATE ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+type enveloped interface {
+	status () *apiStatus
+}
+
+func (this *apiStatus) status () *apiStatus { return this }
+
+var apiStatusType = reflect.TypeOf (apiStatus{})
+
+// finds the ApiStatus in a response, either embedded like respLogin or as an ApiStatus field
+// returns nil if there isn't one
+func envelope (out interface{}) *apiStatus {
+	if e, ok := out.(enveloped); ok { return e.status() }
+
+	val := reflect.ValueOf (out)
+	if val.Kind() != reflect.Ptr || val.IsNil() { return nil }
+
+	val = val.Elem()
+	if val.Kind() != reflect.Struct { return nil }
+
+	field := val.FieldByName ("ApiStatus")
+	if field.IsValid() == false || field.Type() != apiStatusType { return nil }
+
+	return field.Addr().Interface().(*apiStatus)
+}
+
+// keys we've seen used for the field name and message when the errors come back as a list of objects
+var (
+	fieldKeys = []string { "Field", "PropertyName", "Key", "Name", "MemberName" }
+	messageKeys = []string { "Message", "ErrorMessage", "Error", "Description" }
+)
+
+// returns the first string value found with one of these keys, matched case insensitive
+func firstString (obj map[string]interface{}, keys []string) string {
+	for _, key := range keys {
+		for k, v := range obj {
+			if strings.EqualFold (k, key) {
+				if str, ok := v.(string); ok && len(str) > 0 { return str }
+			}
+		}
+	}
+	return ""
+}
+
+// turns whatever we got for a single field into messages
+func fieldMessages (field string, val interface{}) []FieldError {
+	switch v := val.(type) {
+	case string:
+		if len(v) == 0 { return nil }
+		return []FieldError {{ Field: field, Message: v }}
+
+	case []interface{}:
+		var ret []FieldError
+		for _, child := range v { ret = append (ret, fieldMessages (field, child)...) }
+		return ret
+
+	case map[string]interface{}:
+		if name := firstString (v, fieldKeys); len(name) > 0 { field = name }
+
+		if msg := firstString (v, messageKeys); len(msg) > 0 {
+			return []FieldError {{ Field: field, Message: msg }}
+		}
+
+		// nested by field name, like {"Address": {"Zip": "invalid"}}
+		ret := decodeFieldErrors (v)
+		if len(field) > 0 {
+			for i := range ret {
+				if len(ret[i].Field) > 0 {
+					ret[i].Field = field + "." + ret[i].Field
+				} else {
+					ret[i].Field = field
+				}
+			}
+		}
+		return ret
+	}
+	return nil
+}
+
+// decodes the ApiStatus.Errors payload, which comes back in a few different shapes
+//   {"FirstName": "required"} or {"FirstName": ["required", "too short"]}
+//   [{"Field": "FirstName", "Message": "required"}] with a few different key names
+//   ["FirstName is required"]
+func decodeFieldErrors (payload interface{}) []FieldError {
+	switch v := payload.(type) {
+	case map[string]interface{}:
+		if len(firstString (v, fieldKeys)) > 0 && len(firstString (v, messageKeys)) > 0 {
+			return fieldMessages ("", v) // it's a single error object
+		}
+
+		keys := make([]string, 0, len(v))
+		for key := range v { keys = append (keys, key) }
+		sort.Strings (keys) // so we're consistent
+
+		var ret []FieldError
+		for _, key := range keys { ret = append (ret, fieldMessages (key, v[key])...) }
+		return ret
+	}
+	return fieldMessages ("", payload)
+}
+
+// strips the query string off the link, so we only have the endpoint
+func endpoint (link string) string {
+	if i := strings.Index (link, "?"); i >= 0 { return link[:i] }
+	return link
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- FUNCTIONS -------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// returns the rejected fields from a serviceworks error, or nil if there aren't any
+func FieldErrors (err error) []FieldError {
+	apiErr := AsAPIError (err)
+	if apiErr == nil { return nil }
+	return apiErr.Fields
+}
+
+// returns the *APIError in this chain, or nil if it's not from serviceworks
+func AsAPIError (err error) *APIError {
+	var apiErr *APIError
+	if errors.As (err, &apiErr) { return apiErr }
+	return nil
+}
+
+//----- CLASSIFICATION ------------------------------------------------------------------------------------------------//
+
+// returns true if this timed out, either the http client's timeout or serviceworks not answering in time
+// a deadline on the caller's own context looks the same, so check ctx.Err() first to tell them apart
+func isTimeout (err error) bool {
+	var netErr net.Error
+	return errors.As (err, &netErr) && netErr.Timeout()
+}
+
+// returns true if trying the same call again later might work
+// network errors, timeouts, 5xx and 429 responses and an open circuit breaker are retryable, cancellations are not
+// a deadline on your own context also reads as a timeout, so check ctx.Err() if you set one
+func IsRetryable (err error) bool {
+	if err == nil { return false }
+	if errors.Is (err, context.Canceled) { return false } // caller gave up
+	if isTimeout (err) { return true } // includes WithTimeout and http.Client.Timeout
+	if errors.Is (err, ErrCircuitOpen) { return true }
+
+	if apiErr := AsAPIError (err); apiErr != nil {
+		return apiErr.HTTPStatus == http.StatusTooManyRequests || apiErr.HTTPStatus > 499
+	}
+
+	var urlErr *url.Error
+	if errors.As (err, &urlErr) { return true } // transport level failure, connection refused, reset, etc
+
+	var netErr net.Error
+	return errors.As (err, &netErr)
+}
+
+// returns true if the token or credentials were rejected, so we need to log in again or fix the password
+func IsAuth (err error) bool {
+	return errors.Is (err, ErrInvalidCode) || errors.Is (err, ErrAuthExpired) || errors.Is (err, ErrInvalidUserPassword)
+}
+
+// returns true if the thing we asked for doesn't exist
+func IsNotFound (err error) bool {
+	return errors.Is (err, ErrNotFound)
+}
+
+// returns true if the thing we asked for was archived or deleted, ie updating an archived job
+func IsGone (err error) bool {
+	return errors.Is (err, ErrGone)
+}
+
+// returns true if serviceworks rejected what we sent it, check FieldErrors for the details
+func IsValidation (err error) bool {
+	apiErr := AsAPIError (err)
+	if apiErr == nil { return false }
+
+	if len(apiErr.Fields) > 0 { return true }
+	return apiErr.HTTPStatus == http.StatusBadRequest || apiErr.HTTPStatus == http.StatusUnprocessableEntity
+}
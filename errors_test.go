@@ -0,0 +1,156 @@
+
+package serviceworks 
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/BeelineRoutes/service-works/swtest"
+
+	"testing"
+	"context"
+	"errors"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+// failed ApiStatus responses
+func TestAPIError1 (t *testing.T) {
+	server := swtest.NewServer()
+	defer server.Close()
+
+	sw := New (WithBaseURL (server.URL))
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	_, err := sw.CrewList (ctx, "bad token")
+	if err == nil { t.Fatal ("we were expecting an error") }
+
+	assert.Equal (t, true, errors.Is (err, ErrInvalidCode))
+
+	var apiErr *APIError
+	if errors.As (err, &apiErr) == false { t.Fatal ("expecting an APIError") }
+
+	assert.Equal (t, "Configuration/GetUserLists", apiErr.Endpoint)
+	assert.Equal (t, http.StatusOK, apiErr.HTTPStatus)
+	assert.Equal (t, 2, apiErr.Status)
+	assert.Equal (t, "invalid token", apiErr.Message)
+
+	// the validation payload comes through
+	_, err = sw.CreateCustomer (ctx, server.Token(), "", "Dogg", "", "", "", "", "", "", "")
+	if err == nil { t.Fatal ("we were expecting an error") }
+
+	apiErr = AsAPIError (err)
+	if apiErr == nil { t.Fatal ("expecting an APIError") }
+
+	assert.Equal (t, true, errors.Is (err, ErrBadResponse))
+	assert.Equal (t, "Job/AddEditCustomerDetail", apiErr.Endpoint)
+	assert.Equal (t, map[string]interface{} { "FirstName": "First Name is required" }, apiErr.Errors)
+	assert.Equal (t, []FieldError {{ "FirstName", "First Name is required" }}, FieldErrors (err))
+}
+
+// http level errors
+func TestAPIError2 (t *testing.T) {
+	server := httptest.NewServer (http.HandlerFunc (func (w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader (http.StatusUnauthorized)
+		w.Write ([]byte(`{"Error":"unauthorized","error_description":"token revoked"}`))
+	}))
+	defer server.Close()
+
+	sw := New (WithBaseURL (server.URL))
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	_, err := sw.SearchCustomers (ctx, "token", "nate dogg")
+	if err == nil { t.Fatal ("we were expecting an error") }
+
+	assert.Equal (t, true, errors.Is (err, ErrAuthExpired))
+
+	apiErr := AsAPIError (err)
+	if apiErr == nil { t.Fatal ("expecting an APIError") }
+
+	assert.Equal (t, "Job/GetCustomerSearch", apiErr.Endpoint)
+	assert.Equal (t, http.StatusUnauthorized, apiErr.HTTPStatus)
+	assert.Equal (t, "token revoked", apiErr.Description)
+}
+
+// the different shapes of the errors payload
+func TestFieldErrors1 (t *testing.T) {
+	tests := []struct {
+		payload string 
+		expected []FieldError
+	}{
+		{ `null`, nil },
+		{ `"Something went wrong"`, []FieldError {{ "", "Something went wrong" }} },
+		{ `["Email is invalid", "Phone is invalid"]`, []FieldError {{ "", "Email is invalid" }, { "", "Phone is invalid" }} },
+		{ `{"LastName": "required", "FirstName": ["required", "too short"]}`, 
+			[]FieldError {{ "FirstName", "required" }, { "FirstName", "too short" }, { "LastName", "required" }} },
+		{ `[{"PropertyName": "Zip", "ErrorMessage": "invalid"}, {"field": "State", "message": "missing"}]`, 
+			[]FieldError {{ "Zip", "invalid" }, { "State", "missing" }} },
+		{ `{"Field": "CustomerId", "Message": "Customer not found"}`, []FieldError {{ "CustomerId", "Customer not found" }} },
+		{ `{"Address": {"Zip": "invalid"}}`, []FieldError {{ "Address.Zip", "invalid" }} },
+	}
+
+	for _, test := range tests {
+		var payload interface{}
+		if err := json.Unmarshal ([]byte(test.payload), &payload); err != nil { t.Fatal (err) }
+
+		assert.Equal (t, test.expected, decodeFieldErrors (payload), test.payload)
+	}
+}
+
+// sorting errors into what a worker should do with them
+func TestClassify1 (t *testing.T) {
+	server := swtest.NewServer()
+	defer server.Close()
+
+	sw := New (WithBaseURL (server.URL))
+	token := server.Token()
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	// auth
+	_, err := sw.CrewList (ctx, "bad token")
+	assert.Equal (t, true, IsAuth (err))
+	assert.Equal (t, false, IsRetryable (err))
+
+	_, err = sw.Login (ctx, swtest.Username, "bad password", swtest.ApiKey)
+	assert.Equal (t, true, IsAuth (err))
+
+	// validation
+	_, err = sw.CreateCustomer (ctx, token, "", "Dogg", "", "", "", "", "", "", "")
+	assert.Equal (t, true, IsValidation (err))
+	assert.Equal (t, false, IsAuth (err))
+
+	// not found
+	err = sw.JobUpdate (ctx, token, 1, 1, 60, 1, 1, time.Now(), []int{ 1694 })
+	assert.Equal (t, true, IsNotFound (err))
+
+	// gone
+	c := server.AddCustomer (swtest.Customer { FirstName: "Nate", LastName: "Dogg" })
+	j := server.AddJob (swtest.Job { CustomerId: c.CustomerId })
+	server.ArchiveJob (j.TicketId)
+
+	err = sw.JobUpdate (ctx, token, j.TicketId, j.TripAssignmentId, 60, 1, 1, time.Now(), []int{ 1694 })
+	assert.Equal (t, true, IsGone (err))
+	assert.Equal (t, false, IsRetryable (err))
+
+	// 5xx and network errors
+	broken := httptest.NewServer (http.HandlerFunc (func (w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader (http.StatusInternalServerError)
+	}))
+
+	_, err = New (WithBaseURL (broken.URL)).CrewList (ctx, token)
+	assert.Equal (t, true, IsRetryable (err))
+
+	broken.Close()
+
+	_, err = New (WithBaseURL (broken.URL)).CrewList (ctx, token)
+	assert.Equal (t, true, IsRetryable (err))
+
+	assert.Equal (t, false, IsRetryable (nil))
+	assert.Equal (t, false, IsRetryable (context.Canceled))
+}
@@ -0,0 +1,86 @@
+/** ****************************************************************************************************************** **
+	Extra fields
+
+	Serviceworks sends a lot more than we model, and it adds fields without telling anyone.
+	Our models keep anything they don't know about in Extra, and write it back out when they're marshaled,
+	so the fields we never modeled survive a json round trip, ie caching a customer and reading it back.
+	Our update calls send only the fields they're given, Extra isn't sent to serviceworks.
+
+** ****************************************************************************************************************** **/
+
+package serviceworks
+
+import (
+	"github.com/pkg/errors"
+
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- CONSTS ----------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+var extraType = reflect.TypeOf (map[string]json.RawMessage{})
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- PRIVATE ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// returns true if this is one of our models that keeps its unknown fields
+// they decode themselves, but strict decoding still checks them
+func hasExtra (typ reflect.Type) bool {
+	if typ.Kind() != reflect.Struct { return false }
+
+	f, ok := typ.FieldByName ("Extra")
+	return ok && f.Type == extraType
+}
+
+// decodes b into obj and returns the fields obj doesn't have, nil if there aren't any
+// obj should be an alias of the model, so this doesn't end up back in the model's UnmarshalJSON
+// encoding/json can't hand us the unknown fields, so the object is split into raw values first, which doesn't decode them
+func decodeWithExtra[T any] (b []byte, obj *T) (map[string]json.RawMessage, error) {
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal (b, &all); err != nil { return nil, errors.WithStack (err) }
+
+	if err := json.Unmarshal (b, obj); err != nil { return nil, errors.WithStack (err) }
+
+	fields := jsonFields (reflect.TypeOf (obj).Elem())
+	for key := range all {
+		if _, ok := fields[strings.ToLower (key)]; ok { delete (all, key) }
+	}
+
+	if len(all) == 0 { return nil, nil }
+	return all, nil
+}
+
+// marshals obj and adds the extra fields onto the end, obj's own fields win if they're in both
+// like decodeWithExtra, obj should be an alias of the model
+func encodeWithExtra[T any] (obj T, extra map[string]json.RawMessage) ([]byte, error) {
+	b, err := json.Marshal (obj)
+	if err != nil || len(extra) == 0 { return b, errors.WithStack (err) }
+
+	fields := jsonFields (reflect.TypeOf (obj))
+
+	keys := make([]string, 0, len(extra))
+	for key := range extra {
+		if _, ok := fields[strings.ToLower (key)]; ok == false { keys = append (keys, key) }
+	}
+	if len(keys) == 0 { return b, nil }
+	sort.Strings (keys) // so the output is stable
+
+	buf := bytes.NewBuffer (b[:len(b)-1]) // drop the closing brace
+	for i, key := range keys {
+		if i > 0 || len(b) > 2 { buf.WriteByte (',') } // b is {} if obj didn't write anything
+
+		name, _ := json.Marshal (key)
+		buf.Write (name)
+		buf.WriteByte (':')
+		buf.Write (extra[key])
+	}
+	buf.WriteByte ('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,73 @@
+package serviceworks 
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"testing"
+	"encoding/json"
+)
+
+// fields we don't model survive a round trip
+func TestExtraFields1 (t *testing.T) {
+	body := `{"CustomerId":20147,"FirstName":"Harry","LastName":"Potter","IsActive":true,"Birthday":"07/31/1980",
+		"Addresses":[{"AddressId":5,"AddressLine1":"4 Privet Dr","Latitude2":"44.3","Type":2}]}`
+
+	var c Customer
+	if err := json.Unmarshal ([]byte(body), &c); err != nil { t.Fatal (err) }
+
+	assert.Equal (t, 20147, c.CustomerId)
+	assert.Equal (t, "Harry", c.FirstName)
+	assert.Equal (t, map[string]json.RawMessage { "Birthday": json.RawMessage(`"07/31/1980"`) }, c.Extra)
+	assert.Equal (t, "4 Privet Dr", c.Addresses[0].AddressLine1)
+	assert.Equal (t, map[string]json.RawMessage { "Latitude2": json.RawMessage(`"44.3"`) }, c.Addresses[0].Extra)
+
+	// our changes go out with the extras
+	c.FirstName = "Dudley"
+	out, err := json.Marshal (c)
+	if err != nil { t.Fatal (err) }
+
+	var check map[string]interface{}
+	assert.Nil (t, json.Unmarshal (out, &check))
+	assert.Equal (t, "Dudley", check["FirstName"])
+	assert.Equal (t, "07/31/1980", check["Birthday"])
+	assert.Equal (t, "44.3", check["Addresses"].([]interface{})[0].(map[string]interface{})["Latitude2"])
+	assert.Nil (t, check["Extra"])
+
+	// our fields win if the extras have the same one
+	c.Extra["firstname"] = json.RawMessage(`"Vernon"`)
+	out, _ = json.Marshal (c)
+	check = nil
+	json.Unmarshal (out, &check)
+	assert.Equal (t, "Dudley", check["FirstName"])
+	assert.Nil (t, check["firstname"])
+}
+
+// nothing extra looks the same as before
+func TestExtraFields2 (t *testing.T) {
+	var j Job
+	assert.Nil (t, json.Unmarshal ([]byte(`{"TicketId":4,"CustomerName":"Nate Dogg"}`), &j))
+	assert.Nil (t, j.Extra)
+
+	type alias Job
+	plain, _ := json.Marshal (alias(j))
+	out, _ := json.Marshal (j)
+	assert.Equal (t, string(plain), string(out))
+
+	var e Employee
+	assert.Nil (t, json.Unmarshal ([]byte(`{"EmployeeID":1694,"HourlyRate":22.5}`), &e))
+	assert.Equal (t, 1694, e.EmployeeID)
+	assert.Equal (t, json.RawMessage(`22.5`), e.Extra["HourlyRate"])
+
+	var a Assignment
+	assert.Nil (t, json.Unmarshal ([]byte(`{"TripNo":2,"Notes":null}`), &a))
+	assert.Equal (t, 2, a.TripNo)
+	assert.Equal (t, json.RawMessage(`null`), a.Extra["Notes"])
+
+	// extras still make valid json when the object itself writes nothing
+	out, err := encodeWithExtra (struct{}{}, map[string]json.RawMessage { "b": json.RawMessage(`2`), "a": json.RawMessage(`"1"`) })
+	assert.Nil (t, err)
+	assert.Equal (t, `{"a":"1","b":2}`, string(out))
+
+	// bad json is still an error
+	assert.NotNil (t, json.Unmarshal ([]byte(`{"TicketId":"four"}`), &j))
+}
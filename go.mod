@@ -1,6 +1,6 @@
 module github.com/BeelineRoutes/service-works
 
-go 1.19
+go 1.21
 
 require (
 	github.com/pkg/errors v0.9.1
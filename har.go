@@ -0,0 +1,278 @@
+/** ****************************************************************************************************************** **
+	HAR export
+
+	Captures every request and response made by a ServiceWorks object while capture is on,
+	and exports them as an HTTP Archive (HAR 1.2) so they can be opened in a browser or sent to serviceworks support.
+	Secret headers are always masked, and bodies and query strings follow the same redaction as our errors.
+
+** ****************************************************************************************************************** **/
+
+package serviceworks
+
+import (
+	"github.com/pkg/errors"
+
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- CONSTS ----------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+const harVersion = "1.2"
+
+// used in debug mode, so the secrets are still masked even when the pii isn't
+var secretRedactor = newRedactor ([]string{}, false)
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- STRUCTS ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+type HARCreator struct {
+	Name string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HAREntry struct {
+	StartedDateTime string `json:"startedDateTime"`
+	Time float64 `json:"time"` // milliseconds
+	Request HARRequest `json:"request"`
+	Response HARResponse `json:"response"`
+	Cache struct{} `json:"cache"`
+	Timings HARTimings `json:"timings"`
+	Error string `json:"_error,omitempty"` // set when we didn't get a response
+}
+
+type HARRequest struct {
+	Method string `json:"method"`
+	URL string `json:"url"`
+	HTTPVersion string `json:"httpVersion"`
+	Cookies []HARPair `json:"cookies"`
+	Headers []HARPair `json:"headers"`
+	QueryString []HARPair `json:"queryString"`
+	PostData *HARPostData `json:"postData,omitempty"`
+	HeadersSize int `json:"headersSize"`
+	BodySize int `json:"bodySize"`
+}
+
+type HARResponse struct {
+	Status int `json:"status"` // 0 if we didn't get a response
+	StatusText string `json:"statusText"`
+	HTTPVersion string `json:"httpVersion"`
+	Cookies []HARPair `json:"cookies"`
+	Headers []HARPair `json:"headers"`
+	Content HARContent `json:"content"`
+	RedirectURL string `json:"redirectURL"`
+	HeadersSize int `json:"headersSize"`
+	BodySize int `json:"bodySize"`
+}
+
+// used for headers, cookies and query strings
+type HARPair struct {
+	Name string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text string `json:"text"`
+}
+
+type HARContent struct {
+	Size int `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text string `json:"text,omitempty"`
+}
+
+type HARTimings struct {
+	Send float64 `json:"send"`
+	Wait float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// holds what we've captured so far
+type harRecorder struct {
+	lock sync.Mutex
+	on bool
+	entries []HAREntry
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- PRIVATE ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+func (this *harRecorder) capturing () bool {
+	if this == nil { return false }
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return this.on
+}
+
+func (this *harRecorder) add (entry HAREntry) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if this.on { this.entries = append (this.entries, entry) } // capture may have stopped while this call was out
+}
+
+func millis (d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// returns the headers in a stable order with the secrets masked
+func harHeaders (header http.Header) []HARPair {
+	keys := make([]string, 0, len(header))
+	for key := range header { keys = append (keys, key) }
+	sort.Strings (keys)
+
+	ret := make([]HARPair, 0, len(keys))
+	for _, key := range keys {
+		for _, val := range header[key] {
+			if secretHeader (key) { val = redactedValue }
+			ret = append (ret, HARPair { Name: key, Value: val })
+		}
+	}
+	return ret
+}
+
+// records this call if we're capturing
+// resp and respBody are nil if we didn't get a response
+func (this *ServiceWorks) captureHAR (req *http.Request, reqBody []byte, resp *http.Response, respBody []byte, start time.Time, err error) {
+	if this.capture.capturing() == false { return }
+
+	r := this.redactor()
+	if r.debug { r = secretRedactor } // these files get sent to other people
+	took := time.Since (start)
+
+	link := r.link (req.URL.String())
+
+	entry := HAREntry {
+		StartedDateTime: start.Format ("2006-01-02T15:04:05.000Z07:00"),
+		Time: millis (took),
+		Request: HARRequest {
+			Method: req.Method,
+			URL: link,
+			HTTPVersion: "HTTP/1.1",
+			Cookies: []HARPair{},
+			Headers: harHeaders (req.Header),
+			QueryString: []HARPair{},
+			HeadersSize: -1,
+			BodySize: len(reqBody),
+		},
+		Response: HARResponse {
+			HTTPVersion: "HTTP/1.1",
+			Cookies: []HARPair{},
+			Headers: []HARPair{},
+			HeadersSize: -1,
+			BodySize: -1,
+		},
+		Timings: HARTimings { Send: 0, Wait: millis (took), Receive: 0 }, // we only know the total
+	}
+
+	if u, perr := url.Parse (link); perr == nil {
+		keys := make([]string, 0)
+		params := u.Query()
+		for key := range params { keys = append (keys, key) }
+		sort.Strings (keys)
+
+		for _, key := range keys {
+			for _, val := range params[key] {
+				entry.Request.QueryString = append (entry.Request.QueryString, HARPair { Name: key, Value: val })
+			}
+		}
+	}
+
+	if len(reqBody) > 0 {
+		entry.Request.PostData = &HARPostData { MimeType: req.Header.Get ("Content-Type"), Text: r.text (string(reqBody)) }
+	}
+
+	if resp != nil {
+		entry.Response.Status = resp.StatusCode
+		entry.Response.StatusText = http.StatusText (resp.StatusCode)
+		entry.Response.HTTPVersion = resp.Proto
+		entry.Response.Headers = harHeaders (resp.Header)
+		entry.Response.BodySize = len(respBody)
+		entry.Response.Content = HARContent {
+			Size: len(respBody),
+			MimeType: resp.Header.Get ("Content-Type"),
+			Text: r.text (string(respBody)),
+		}
+	}
+
+	if err != nil { entry.Error = safeErr (err) }
+
+	this.capture.add (entry)
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- FUNCTIONS -------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// starts capturing our traffic, anything captured before is dropped
+func (this *ServiceWorks) StartCapture () {
+	if this.capture == nil { this.capture = &harRecorder{} } // someone built the struct directly
+
+	this.capture.lock.Lock()
+	defer this.capture.lock.Unlock()
+
+	this.capture.on = true
+	this.capture.entries = nil
+}
+
+// stops capturing, what we have is kept until the next StartCapture
+func (this *ServiceWorks) StopCapture () {
+	if this.capture == nil { return }
+
+	this.capture.lock.Lock()
+	defer this.capture.lock.Unlock()
+
+	this.capture.on = false
+}
+
+// returns true if we're capturing traffic
+func (this *ServiceWorks) Capturing () bool {
+	return this.capture.capturing()
+}
+
+// returns what's been captured so far as a HAR, this works while capture is still on
+func (this *ServiceWorks) HAR () *HAR {
+	ret := &HAR {
+		Log: HARLog {
+			Version: harVersion,
+			Creator: HARCreator { Name: "github.com/BeelineRoutes/service-works", Version: "1.0" },
+			Entries: []HAREntry{},
+		},
+	}
+
+	if this.capture == nil { return ret }
+
+	this.capture.lock.Lock()
+	defer this.capture.lock.Unlock()
+
+	ret.Log.Entries = append (ret.Log.Entries, this.capture.entries...)
+	return ret
+}
+
+// writes what's been captured so far as a .har file
+func (this *ServiceWorks) WriteHAR (w io.Writer) error {
+	enc := json.NewEncoder (w)
+	enc.SetIndent ("", "  ")
+	return errors.WithStack (enc.Encode (this.HAR()))
+}
@@ -0,0 +1,93 @@
+package serviceworks 
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/BeelineRoutes/service-works/swtest"
+
+	"testing"
+	"context"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// capturing traffic to a HAR
+func TestHAR1 (t *testing.T) {
+	server := swtest.NewServer()
+	defer server.Close()
+
+	sw := New (WithBaseURL (server.URL))
+	token := server.Token()
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	// nothing until we start
+	_, err := sw.SearchCustomers (ctx, token, "potter")
+	assert.Nil (t, err)
+	assert.Equal (t, false, sw.Capturing())
+	assert.Equal (t, 0, len(sw.HAR().Log.Entries))
+
+	sw.StartCapture()
+	assert.Equal (t, true, sw.Capturing())
+
+	sw.SearchCustomers (ctx, token, "potter")
+	sw.CreateCustomer (ctx, token, "Harry", "Potter", "harry@hogwarts.edu", "", "4 Privet Dr", "", "05482", "Shelburne", "VT")
+
+	sw.StopCapture()
+	sw.SearchCustomers (ctx, token, "weasley") // not captured
+
+	har := sw.HAR()
+	assert.Equal (t, "1.2", har.Log.Version)
+	if assert.Equal (t, 2, len(har.Log.Entries)) {
+		search := har.Log.Entries[0]
+		assert.Equal (t, "GET", search.Request.Method)
+		assert.Equal (t, true, strings.HasPrefix (search.Request.URL, server.URL + "/api/Job/GetCustomerSearch?"))
+		assert.Equal (t, []HARPair {{ Name: "CustomerName", Value: "REDACTED" }}, search.Request.QueryString)
+		assert.Equal (t, 200, search.Response.Status)
+		assert.Nil (t, search.Request.PostData)
+
+		create := har.Log.Entries[1]
+		assert.Equal (t, "POST", create.Request.Method)
+		assert.NotNil (t, create.Request.PostData)
+		assert.Equal (t, true, create.Response.Content.Size > 0)
+	}
+
+	var buf bytes.Buffer
+	assert.Nil (t, sw.WriteHAR (&buf))
+	assert.Equal (t, false, strings.Contains (buf.String(), token))
+	assert.Equal (t, false, strings.Contains (buf.String(), "potter"))
+	assert.Equal (t, false, strings.Contains (buf.String(), "harry@hogwarts.edu"))
+
+	var check map[string]interface{}
+	assert.Nil (t, json.Unmarshal (buf.Bytes(), &check))
+
+	// starting again drops what we had
+	sw.StartCapture()
+	assert.Equal (t, 0, len(sw.HAR().Log.Entries))
+}
+
+// secrets are masked even in debug mode
+func TestHAR2 (t *testing.T) {
+	server := swtest.NewServer()
+	defer server.Close()
+
+	sw := New (WithBaseURL (server.URL), WithDebug (true))
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	sw.StartCapture()
+	login, err := sw.Login (ctx, swtest.Username, swtest.Password, swtest.ApiKey)
+	if err != nil { t.Fatal (err) }
+
+	sw.SearchCustomers (ctx, login.Token, "potter")
+
+	var buf bytes.Buffer
+	assert.Nil (t, sw.WriteHAR (&buf))
+	assert.Equal (t, false, strings.Contains (buf.String(), swtest.Password))
+	assert.Equal (t, false, strings.Contains (buf.String(), swtest.ApiKey))
+	assert.Equal (t, false, strings.Contains (buf.String(), login.Token))
+	assert.Equal (t, true, strings.Contains (buf.String(), "potter")) // pii is fine in debug mode
+}
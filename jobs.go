@@ -11,11 +11,13 @@ package serviceworks
 
 import (
     "github.com/pkg/errors"
+    "github.com/BeelineRoutes/service-works/swtime"
 
     "fmt"
     "net/http"
     "net/url"
     "context"
+    "encoding/json"
     "time"
 )
 
@@ -27,19 +29,50 @@ type TimeRange struct {
     Id, Text string
 }
 
+// an employee assigned to a trip
+type Technician struct {
+    TripDetailsId, EmployeeId int
+    EmployeeName string 
+}
+
+type Trip struct {
+    TripDetailsId, EmployeeId int
+    CompletionTime swtime.Time // zero if it hasn't been completed
+}
+
+// returns when this trip was completed in the company's location, zero if it hasn't been
+func (this *Trip) CompletedAt (loc *time.Location) time.Time {
+    return this.CompletionTime.At (loc)
+}
+
 type Assignment struct {
     TripAssignmentId, TripNo, Duration, TimeRangeId int 
-    AssignDateTime, TimeRange, AssignTime string 
+    AssignDateTime swtime.Time // wall clock in the company's timezone, zero if it's not scheduled
+    TimeRange, AssignTime string 
 
-    AssignmentDetails []struct {
-        TripDetailsId, EmployeeId int
-        EmployeeName string 
-    }
+    AssignmentDetails []Technician
 
-    TripList []struct {
-        TripDetailsId, EmployeeId int
-        CompletionTime string 
-    }
+    TripList []Trip
+
+    Extra map[string]json.RawMessage `json:"-"` // fields we don't model, written back out when this is marshaled
+}
+
+// returns the scheduled time in the company's location, zero if it's not scheduled
+func (this *Assignment) AssignedAt (loc *time.Location) time.Time {
+    return this.AssignDateTime.At (loc)
+}
+
+// keeps the fields we don't model in Extra
+func (this *Assignment) UnmarshalJSON (b []byte) (err error) {
+    type alias Assignment // so we don't end up back in here
+    this.Extra, err = decodeWithExtra (b, (*alias)(this))
+    return
+}
+
+// writes the fields from Extra back out, so they survive a json round trip
+func (this Assignment) MarshalJSON () ([]byte, error) {
+    type alias Assignment
+    return encodeWithExtra (alias(this), this.Extra)
 }
 
 type Job struct {
@@ -48,18 +81,61 @@ type Job struct {
     IssueDescription, TicketStatus string
 
     TripAssignmentId, TripNo, TimeRangeId int 
-    AssignDateTime, AssignTime, Team, TeamIds string 
+    AssignDateTime swtime.Time // wall clock in the company's timezone, zero if it's not scheduled
+    AssignTime, Team, TeamIds string 
 
     CustomerId int 
     CustomerName, CustomerAddress, ContactPhone string 
+
+    Extra map[string]json.RawMessage `json:"-"` // fields we don't model, written back out when this is marshaled
+}
+
+// keeps the fields we don't model in Extra
+func (this *Job) UnmarshalJSON (b []byte) (err error) {
+    type alias Job // so we don't end up back in here
+    this.Extra, err = decodeWithExtra (b, (*alias)(this))
+    return
+}
+
+// writes the fields from Extra back out, so they survive a json round trip
+func (this Job) MarshalJSON () ([]byte, error) {
+    type alias Job
+    return encodeWithExtra (alias(this), this.Extra)
+}
+
+// returns the scheduled time in the company's location, zero if it's not scheduled
+func (this *Job) AssignedAt (loc *time.Location) time.Time {
+    return this.AssignDateTime.At (loc)
 }
 
 func (this *Job) IsUnscheduled () bool {
-    switch this.TicketStatusId {
-    case JobStatus_unassigned, JobStatus_unscheduled:
-        return true 
-    }
-    return false 
+    return this.TicketStatusId.IsUnscheduled()
+}
+
+// puts a decoded time in the company's location, keeping its wall clock, or its instant if it had an offset
+func inLocation (t swtime.Time, loc *time.Location) swtime.Time {
+    if loc == nil || t.IsZero() { return t }
+    return t.InLocation (loc)
+}
+
+// our times decode as wall clock in UTC, this moves them to the company's location from WithLocation
+// ones sent with an offset keep their instant
+func (this *Job) setLocation (loc *time.Location) {
+    this.AssignDateTime = inLocation (this.AssignDateTime, loc)
+}
+
+// archived jobs come back as a 410, this says it was a job that's gone
+func jobErr (errObj *Error) error {
+    err := errObj.Err()
+    if apiErr := AsAPIError (err); apiErr != nil && apiErr.Err == ErrGone { apiErr.Err = ErrJobGone }
+    return err
+}
+
+// a status as the company has it set up, from wherever you keep their list, see NewJobStatusSet
+type JobStatusConfig struct {
+    TicketStatusId JobStatus
+    TicketStatus string // the name the company sees
+    IsActive bool 
 }
 
 type jobCreate struct {
@@ -97,12 +173,16 @@ func (this *ServiceWorks) JobsListTimeRanges (ctx context.Context, token string)
 
     // see if the response was what was expected
     err = resp.ApiStatus.Error()
-    return resp.Data, wrapErr(err, nil, resp) // and return
+    return resp.Data, this.wrapErr(err, nil, resp) // and return
 }
 
-// creates a new job
-func (this *ServiceWorks) JobCreate (ctx context.Context, token, issueDesc string, customerId, duration, timeRangeId int, target time.Time, 
+// first half of JobCreate, makes the ticket without a schedule or crew
+// this isn't safe to replay once it's worked, it would make a second ticket
+func (this *ServiceWorks) createJob (ctx context.Context, token, issueDesc string, customerId, duration, timeRangeId int, target time.Time, 
                                     employeeIds []int) (*Job, error) {
+    // check this before we make the ticket, otherwise it's left without a crew
+    if len(employeeIds) == 0 { return nil, errors.Errorf("Need at least one employee to create a job") }
+
     header := make(map[string]string)
     header["Token"] = token 
 
@@ -114,7 +194,7 @@ func (this *ServiceWorks) JobCreate (ctx context.Context, token, issueDesc strin
 
     req.CustomerId = customerId
     req.IssueDescription = issueDesc
-    req.AssignDateTime = target.Format("01/02/2006 15:04:00")
+    req.AssignDateTime = swtime.Format (target, locationFrom (ctx))
     req.Duration = duration
     req.AssignTime = "TimeRange"
     req.TimeRangeId = timeRangeId
@@ -131,14 +211,14 @@ func (this *ServiceWorks) JobCreate (ctx context.Context, token, issueDesc strin
     // see if the response was what was expected
     
     err = resp.ApiStatus.Error()
-    if err != nil { return nil, wrapErr(err, req, resp) }
+    if err != nil { return nil, this.wrapErr(err, req, resp) }
 
     // make sure we got a job
-    if len(resp.Jobs) == 0 { return nil, wrapErr(errors.Errorf("Didn't get any jobs back"), req, resp) }
+    if len(resp.Jobs) == 0 { return nil, this.wrapErr(errors.Errorf("Didn't get any jobs back"), req, resp) }
     
     j := resp.Jobs[0] // so it's easier to reference
 
-    if len(j.Assignments) == 0 { return nil, wrapErr(errors.Errorf("Didn't get any job assignments back"), req, resp) }
+    if len(j.Assignments) == 0 { return nil, this.wrapErr(errors.Errorf("Didn't get any job assignments back"), req, resp) }
     a := j.Assignments[0]
 
     ret := &Job {
@@ -162,16 +242,28 @@ func (this *ServiceWorks) JobCreate (ctx context.Context, token, issueDesc strin
     ret.TeamIds = fmt.Sprintf("%d", employeeIds[0]) // just use the first
 
     if len(j.Customer.Addresses) > 0 {
-        ret.CustomerAddress = fmt.Sprintf ("%s %s %s, %s %s", j.Customer.Addresses[0].AddressLine1, j.Customer.Addresses[0].AddressLine2, 
-                            j.Customer.Addresses[0].City, j.Customer.Addresses[0].State, j.Customer.Addresses[0].Zip)
+        ret.CustomerAddress = j.Customer.Addresses[0].human()
     }
 
+    ret.setLocation (locationFrom (ctx))
+    return ret, nil
+}
+
+// creates a new job
+// target is sent as wall clock in the company's location from WithLocation, sessions set it for you
+// without one it's sent in its own location, so it should already be in the company's, see RespLogin.Location
+func (this *ServiceWorks) JobCreate (ctx context.Context, token, issueDesc string, customerId, duration, timeRangeId int, target time.Time, 
+                                    employeeIds []int) (*Job, error) {
+    ret, err := this.createJob (ctx, token, issueDesc, customerId, duration, timeRangeId, target, employeeIds)
+    if err != nil { return nil, err }
+
     // now we create the trip schedule and assign it to these employees
-    err = this.JobUpdate (ctx, token, j.TicketId, a.TripAssignmentId, duration, timeRangeId, a.TripNo, target, employeeIds)
+    err = this.JobUpdate (ctx, token, ret.TicketId, ret.TripAssignmentId, duration, timeRangeId, ret.TripNo, target, employeeIds)
     return ret, err // and return
 }
 
 // updates the arrival time or assigned crew or both for an existing job
+// like JobCreate, target is sent in the location from WithLocation, or its own if there isn't one
 func (this *ServiceWorks) JobUpdate (ctx context.Context, token string, ticketId, tripAssignId, duration, timeRangeId, tripNo int, target time.Time, employeeIds []int) error {
     header := make(map[string]string)
     header["Token"] = token 
@@ -186,7 +278,7 @@ func (this *ServiceWorks) JobUpdate (ctx context.Context, token string, ticketId
 
     req.TicketId = ticketId
     req.TripAssignmentId = tripAssignId
-    req.AssignDateTime = target.Format("01/02/2006 15:04:00")
+    req.AssignDateTime = swtime.Format (target, locationFrom (ctx))
     req.Duration = duration
     req.AssignTime = "TimeRange"
     req.TimeRangeId = timeRangeId
@@ -208,17 +300,19 @@ func (this *ServiceWorks) JobUpdate (ctx context.Context, token string, ticketId
     
     errObj, err := this.send (ctx, http.MethodPost, "Job/SaveSchedule", header, &req, &resp)
     if err != nil { return errors.WithStack(err) } // bail
-    if errObj != nil { return errObj.Err() } // something else bad
+    if errObj != nil { return jobErr(errObj) } // something else bad
 
     // see if the response was what was expected
-    return wrapErr(resp.ApiStatus.Error(), req, resp)
+    return this.wrapErr(resp.ApiStatus.Error(), req, resp)
 }
 
 
+// returns the scheduled jobs between these dates, the times come back in the location from WithLocation
+// the dates are sent as the year, month and day they already are, so they aren't converted to the company's location
 func (this *ServiceWorks) ListJobs (ctx context.Context, token string, start, finish time.Time) ([]*Job, error) {
     params := url.Values{}
-    params.Set("fromdate", start.Format("01/02/2006"))
-    params.Set("todate", finish.Format("01/02/2006"))
+    params.Set("fromdate", start.Format(swtime.DateFormat))
+    params.Set("todate", finish.Format(swtime.DateFormat))
     params.Set("roleId", "0")
     params.Set("isDateTrue", "true")
 
@@ -232,7 +326,10 @@ func (this *ServiceWorks) ListJobs (ctx context.Context, token string, start, fi
     if errObj != nil { return nil, errObj.Err() } // something else bad
 
     // see if the response was what was expected
-    err = wrapErr(resp.ApiStatus.Error(), nil, resp)
+    err = this.wrapErr(resp.ApiStatus.Error(), nil, resp)
+
+    loc := locationFrom (ctx)
+    for _, j := range resp.Data { j.setLocation (loc) }
     return resp.Data, err // and return
 }
 
@@ -3,9 +3,13 @@ package serviceworks
 
 import (
 	"github.com/stretchr/testify/assert"
+	"github.com/pkg/errors"
+	"github.com/BeelineRoutes/service-works/swtest"
 
 	"testing"
 	"context"
+	"encoding/json"
+	"strings"
 	"time"
 )
 
@@ -49,3 +53,95 @@ func TestSecondJobs2 (t *testing.T) {
 	assert.Equal (t, true, jobs[0].TripAssignmentId > 0)
 	
 }
+
+// typed times on jobs and assignments
+func TestSecondJobs3 (t *testing.T) {
+	loc, err := time.LoadLocation ("America/Halifax")
+	if err != nil { t.Fatal (err) }
+
+	var j Job
+	assert.Nil (t, json.Unmarshal ([]byte(`{"TicketId":4,"AssignDateTime":"2023-11-30T14:30:00"}`), &j))
+	assert.Equal (t, time.Date (2023, 11, 30, 14, 30, 0, 0, loc), j.AssignedAt (loc))
+
+	var a Assignment
+	assert.Nil (t, json.Unmarshal ([]byte(`{"AssignDateTime":"11/30/2023 14:30:00","TripList":[{"CompletionTime":""}]}`), &a))
+	assert.Equal (t, time.Date (2023, 11, 30, 14, 30, 0, 0, loc), a.AssignedAt (loc))
+	assert.Equal (t, true, a.TripList[0].CompletedAt (loc).IsZero()) // not completed yet
+
+	// ones with an offset keep their instant once they're in the company's location, and on the way back out
+	for _, val := range []string { "2023-11-30T18:30:00Z", "2023-11-30T14:30:00-04:00" } {
+		var z Job
+		assert.Nil (t, json.Unmarshal ([]byte(`{"TicketId":4,"AssignDateTime":"` + val + `"}`), &z))
+		z.setLocation (loc)
+		assert.Equal (t, true, time.Date (2023, 11, 30, 14, 30, 0, 0, loc).Equal (z.AssignedAt (loc)), val)
+
+		out, err := json.Marshal (z)
+		assert.Nil (t, err)
+
+		var back Job
+		assert.Nil (t, json.Unmarshal (out, &back))
+		assert.Equal (t, true, time.Date (2023, 11, 30, 14, 30, 0, 0, loc).Equal (back.AssignedAt (loc)), string(out))
+	}
+
+	// unscheduled jobs write the time back out the way they came in
+	out, err := json.Marshal (Job { TicketId: 5 })
+	assert.Nil (t, err)
+	assert.Equal (t, true, strings.Contains (string(out), `"AssignDateTime":""`))
+
+	assert.NotNil (t, json.Unmarshal ([]byte(`{"AssignDateTime":"tomorrow"}`), &j))
+
+	// and from a search
+	server := swtest.NewServer()
+	defer server.Close()
+
+	server.AddJob (swtest.Job { CustomerId: 20147, Duration: 60, IssueDescription: "fix the sink", AssignDateTime: "2023-11-30T10:00:00" })
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	sw := New (WithBaseURL (server.URL))
+	jobs, err := sw.ListJobs (ctx, server.Token(), time.Date (2023, 11, 30, 0, 0, 0, 0, time.UTC), time.Date (2023, 12, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil { t.Fatal (err) }
+
+	if assert.Equal (t, 1, len(jobs)) {
+		assert.Equal (t, time.Date (2023, 11, 30, 10, 0, 0, 0, loc), jobs[0].AssignedAt (loc))
+	}
+}
+
+// archived jobs, and the customer's address on a new one
+func TestSecondJobs4 (t *testing.T) {
+	server := swtest.NewServer()
+	defer server.Close()
+
+	e1 := server.AddEmployee (swtest.Employee { FirstName: "Harry", LastName: "Potter", IsTechnician: true, IsActive: true })
+	c := server.AddCustomer (swtest.Customer { FirstName: "Nate", LastName: "Dogg", PrimaryPhone: "8025551234",
+		Addresses: []swtest.Address {{ AddressLine1: "23 Potter Pl", City: "Shelburne", State: "VT", Zip: "05482", IsActive: true }},
+	})
+
+	sw := New (WithBaseURL (server.URL))
+	token := server.Token()
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	target := time.Date (2023, 11, 30, 14, 0, 0, 0, time.UTC)
+	created, err := sw.JobCreate (ctx, token, "Broken wand", c.CustomerId, 90, 3, target, []int { e1.EmployeeID })
+	if err != nil { t.Fatal (err) }
+
+	assert.Equal (t, "Nate Dogg", created.CustomerName)
+	assert.Equal (t, "8025551234", created.ContactPhone)
+	assert.Equal (t, "23 Potter Pl Shelburne, VT 05482", created.CustomerAddress)
+
+	// archived
+	server.ArchiveJob (created.TicketId)
+	err = sw.JobUpdate (ctx, token, created.TicketId, created.TripAssignmentId, 90, 3, created.TripNo, target, []int { e1.EmployeeID })
+	assert.Equal (t, true, errors.Is (err, ErrJobGone))
+	assert.Equal (t, true, errors.Is (err, ErrGone))
+	assert.Equal (t, ErrJobGone, errors.Cause (err))
+	assert.Equal (t, true, IsGone (err))
+
+	// and ones that never existed
+	err = sw.JobUpdate (ctx, token, 1, 1, 90, 3, 1, target, []int { e1.EmployeeID })
+	assert.Equal (t, true, IsNotFound (err))
+	assert.Equal (t, false, IsGone (err))
+}
@@ -0,0 +1,185 @@
+/** ****************************************************************************************************************** **
+	Job statuses
+
+	Names for the JobStatus ids we've confirmed, and groupings built from the company's own status list.
+	In json a status is still the number serviceworks sends, the text marshaling is for logs, config and map keys.
+	Other ids are different for each company, so what they mean comes from the names in their list, see JobStatusSet.
+
+** ****************************************************************************************************************** **/
+
+package serviceworks
+
+import (
+	"github.com/pkg/errors"
+
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- CONSTS ----------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+var jobStatusNames = map[JobStatus]string {
+	JobStatus_unassigned: "unassigned",
+	JobStatus_scheduled: "scheduled",
+	JobStatus_unscheduled: "unscheduled",
+	JobStatus_confirmed: "confirmed",
+}
+
+// names that mean the work is done or won't be done, matched against the company's names once normalized
+var closedStatusNames = map[string]bool {
+	"complete": true, "completed": true, "cancelled": true, "canceled": true, "closed": true,
+	"invoiced": true, "paid": true, "void": true,
+}
+
+// names that mean the job has a time and crew
+var scheduledStatusNames = map[string]bool {
+	"scheduled": true, "confirmed": true, "dispatched": true, "en_route": true, "in_progress": true,
+}
+
+var ErrUnknownJobStatus = errors.New("Unknown job status")
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- STRUCTS ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// the company's statuses with what each one means, built from their list
+type JobStatusSet struct {
+	statuses map[JobStatus]JobStatusConfig
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- PRIVATE ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// "In Progress", "in-progress" and "in_progress" are all "in_progress"
+func statusName (name string) string {
+	return strings.ToLower (strings.NewReplacer (" ", "_", "-", "_").Replace (strings.TrimSpace (name)))
+}
+
+// returns the company's name for the status, normalized, empty if it's not in their list
+func (this *JobStatusSet) name (status JobStatus) string {
+	if this == nil { return "" }
+	return statusName (this.statuses[status].TicketStatus)
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- FUNCTIONS -------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// returns the name, ie "scheduled", or JobStatus(21) for ones we don't know
+func (this JobStatus) String () string {
+	if name, ok := jobStatusNames[this]; ok { return name }
+	return fmt.Sprintf ("JobStatus(%d)", int(this))
+}
+
+// returns true if this is one of the statuses we've confirmed
+func (this JobStatus) IsKnown () bool {
+	_, ok := jobStatusNames[this]
+	return ok
+}
+
+// returns the status for this name or id, ie "unscheduled", "Unscheduled" or "7"
+func ParseJobStatus (val string) (JobStatus, error) {
+	val = strings.TrimSpace (val)
+
+	if id, err := strconv.Atoi (val); err == nil { return JobStatus(id), nil } // the others only have an id
+
+	name := statusName (val)
+	for status, n := range jobStatusNames {
+		if n == name || strings.ReplaceAll (n, "_", "") == name { return status, nil }
+	}
+	return 0, errors.Wrapf (ErrUnknownJobStatus, "'%s'", val)
+}
+
+// writes the name, or the id for statuses we don't know
+func (this JobStatus) MarshalText () ([]byte, error) {
+	if name, ok := jobStatusNames[this]; ok { return []byte(name), nil }
+	return []byte(strconv.Itoa (int(this))), nil
+}
+
+func (this *JobStatus) UnmarshalText (b []byte) error {
+	status, err := ParseJobStatus (string(b))
+	if err != nil { return err }
+
+	*this = status
+	return nil
+}
+
+// serviceworks sends and expects the id, so json stays a number rather than using MarshalText
+func (this JobStatus) MarshalJSON () ([]byte, error) {
+	return []byte(strconv.Itoa (int(this))), nil
+}
+
+// takes the id, or a string with the name or id
+func (this *JobStatus) UnmarshalJSON (b []byte) error {
+	if bytes.Equal (b, []byte("null")) { return nil }
+
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal (b, &s); err != nil { return errors.WithStack (err) }
+		return this.UnmarshalText ([]byte(s))
+	}
+
+	var id int
+	if err := json.Unmarshal (b, &id); err != nil { return errors.WithStack (err) }
+
+	*this = JobStatus(id)
+	return nil
+}
+
+// returns true if the job has a time and crew, only for the ids we've confirmed, use JobStatusSet for the others
+func (this JobStatus) IsScheduled () bool {
+	return this == JobStatus_scheduled || this == JobStatus_confirmed
+}
+
+// returns true if the job still needs a time or crew, only for the ids we've confirmed
+func (this JobStatus) IsUnscheduled () bool {
+	return this == JobStatus_unassigned || this == JobStatus_unscheduled
+}
+
+//----- SETS ------------------------------------------------------------------------------------------------------------//
+
+// groups the company's statuses by their names
+// there's no confirmed endpoint for the list yet, so it comes from you, ie your config for the company
+func NewJobStatusSet (statuses []JobStatusConfig) *JobStatusSet {
+	ret := &JobStatusSet { statuses: make(map[JobStatus]JobStatusConfig, len(statuses)) }
+	for _, s := range statuses { ret.statuses[s.TicketStatusId] = s }
+	return ret
+}
+
+// returns the company's config for this status, false if it's not in their list
+func (this *JobStatusSet) Get (status JobStatus) (JobStatusConfig, bool) {
+	if this == nil { return JobStatusConfig{}, false }
+
+	s, ok := this.statuses[status]
+	return s, ok
+}
+
+// returns the name the company sees, or the status's own name if it's not in their list
+func (this *JobStatusSet) Name (status JobStatus) string {
+	if s, ok := this.Get (status); ok && len(s.TicketStatus) > 0 { return s.TicketStatus }
+	return status.String()
+}
+
+// returns true if the work is done or won't be done
+func (this *JobStatusSet) IsClosed (status JobStatus) bool {
+	return closedStatusNames[this.name (status)]
+}
+
+// returns true if the company has this status and it isn't closed, statuses we don't recognize are open
+func (this *JobStatusSet) IsOpen (status JobStatus) bool {
+	_, ok := this.Get (status)
+	return ok && this.IsClosed (status) == false
+}
+
+// returns true if the job has a time and crew
+func (this *JobStatusSet) IsScheduled (status JobStatus) bool {
+	if status.IsScheduled() { return true }
+	if status.IsUnscheduled() { return false }
+	return scheduledStatusNames[this.name (status)]
+}
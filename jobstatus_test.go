@@ -0,0 +1,84 @@
+package serviceworks 
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"testing"
+	"encoding/json"
+	"errors"
+)
+
+// names and text marshaling
+func TestJobStatus1 (t *testing.T) {
+	assert.Equal (t, "unscheduled", JobStatus_unscheduled.String())
+	assert.Equal (t, "JobStatus(21)", JobStatus(21).String())
+	assert.Equal (t, false, JobStatus(21).IsKnown())
+
+	for val, expected := range map[string]JobStatus {
+		"unscheduled": JobStatus_unscheduled,
+		"Unscheduled": JobStatus_unscheduled,
+		"Confirmed": JobStatus_confirmed,
+		"13": JobStatus_confirmed,
+		"21": JobStatus(21), // one only the company knows
+	} {
+		status, err := ParseJobStatus (val)
+		assert.Nil (t, err, val)
+		assert.Equal (t, expected, status, val)
+	}
+
+	_, err := ParseJobStatus ("levitating")
+	assert.Equal (t, true, errors.Is (err, ErrUnknownJobStatus))
+
+	// text is the name, for map keys and config
+	b, err := json.Marshal (map[JobStatus]int { JobStatus_scheduled: 3, JobStatus(21): 1 })
+	assert.Nil (t, err)
+	assert.Equal (t, `{"21":1,"scheduled":3}`, string(b))
+
+	var counts map[JobStatus]int
+	assert.Nil (t, json.Unmarshal (b, &counts))
+	assert.Equal (t, 3, counts[JobStatus_scheduled])
+	assert.Equal (t, 1, counts[JobStatus(21)])
+
+	// but json values stay the id serviceworks uses
+	j := Job { TicketStatusId: JobStatus_scheduled }
+	b, _ = json.Marshal (j)
+	assert.Contains (t, string(b), `"TicketStatusId":2`)
+
+	assert.Nil (t, json.Unmarshal ([]byte(`{"TicketStatusId":4}`), &j))
+	assert.Equal (t, JobStatus(4), j.TicketStatusId)
+
+	assert.Nil (t, json.Unmarshal ([]byte(`{"TicketStatusId":"confirmed"}`), &j))
+	assert.Equal (t, JobStatus_confirmed, j.TicketStatusId)
+}
+
+// groupings from the company's names
+func TestJobStatus2 (t *testing.T) {
+	set := NewJobStatusSet ([]JobStatusConfig {
+		{ TicketStatusId: JobStatus_unassigned, TicketStatus: "Unassigned", IsActive: true },
+		{ TicketStatusId: JobStatus_scheduled, TicketStatus: "Booked", IsActive: true }, // renamed, but the id still counts
+		{ TicketStatusId: JobStatus_unscheduled, TicketStatus: "Unscheduled", IsActive: true },
+		{ TicketStatusId: 4, TicketStatus: "Completed", IsActive: true },
+		{ TicketStatusId: 11, TicketStatus: "En Route", IsActive: true },
+		{ TicketStatusId: 21, TicketStatus: "Waiting on Parts", IsActive: true },
+	})
+
+	assert.Equal (t, true, set.IsScheduled (JobStatus_scheduled))
+	assert.Equal (t, true, set.IsScheduled (JobStatus_confirmed)) // not in their list, but we know it
+	assert.Equal (t, true, set.IsScheduled (11))
+	assert.Equal (t, false, set.IsScheduled (JobStatus_unscheduled))
+	assert.Equal (t, false, set.IsScheduled (21))
+
+	assert.Equal (t, true, set.IsClosed (4))
+	assert.Equal (t, false, set.IsOpen (4))
+	assert.Equal (t, true, set.IsOpen (21))
+	assert.Equal (t, true, set.IsOpen (JobStatus_unassigned))
+
+	// not in their list, so we can't say
+	assert.Equal (t, false, set.IsOpen (30) || set.IsClosed (30) || set.IsScheduled (30))
+
+	assert.Equal (t, "Booked", set.Name (JobStatus_scheduled))
+	assert.Equal (t, "JobStatus(30)", set.Name (30))
+
+	assert.Equal (t, true, (&Job { TicketStatusId: JobStatus_unscheduled }).IsUnscheduled())
+	assert.Equal (t, false, JobStatus(4).IsScheduled() || JobStatus(4).IsUnscheduled())
+}
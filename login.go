@@ -32,15 +32,20 @@ func (this *respLogin) response () (*RespLogin, error) {
         Phone: this.PhoneCode + this.Phone,
         TimeZoneName: this.TimeZoneName,
         CompanyId: fmt.Sprintf("%d", this.Companyid),
+        IssuedAt: time.Now(),
     }, nil
 }
 
 type RespLogin struct {
     Token, Phone, TimeZoneName, CompanyId string
+    IssuedAt time.Time // when we got this token from serviceworks
 }
 
 func (this *RespLogin) ExpiresAt () time.Time {
-    return time.Now().AddDate(0, 0, 29) // i was told this expires in 30 days... so just go with 29
+    issued := this.IssuedAt
+    if issued.IsZero() { issued = time.Now() } // built by hand, so assume it's new
+
+    return issued.AddDate(0, 0, 29) // i was told this expires in 30 days... so just go with 29
 }
 
   //-----------------------------------------------------------------------------------------------------------------------//
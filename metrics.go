@@ -0,0 +1,247 @@
+/** ****************************************************************************************************************** **
+	Per endpoint metrics
+
+	Counts requests, errors by class and latency for each endpoint, and serves them in the prometheus text format.
+	There's no dependency on the prometheus client, it's just a handler you can mount on your /metrics route.
+	Endpoints are labeled without their query string, and numeric path segments become :id, so the label count stays small.
+
+** ****************************************************************************************************************** **/
+
+package serviceworks
+
+import (
+	"github.com/pkg/errors"
+
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- CONSTS ----------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// latency buckets in seconds
+var DefaultMetricBuckets = []float64 { 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30 }
+
+var numericSegment = regexp.MustCompile(`^[0-9]+$`)
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- STRUCTS ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+type metricKey struct {
+	endpoint, method string
+}
+
+type endpointMetrics struct {
+	requests map[int]uint64 // by http status, 0 if we didn't get a response
+	errors map[string]uint64 // by class
+	buckets []uint64 // counts per bucket, not cumulative
+	sum float64
+	count uint64
+}
+
+// collects metrics for one or more ServiceWorks objects
+type Metrics struct {
+	buckets []float64
+
+	lock sync.Mutex
+	endpoints map[metricKey]*endpointMetrics
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- PRIVATE ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// strips the query and replaces ids in the path
+func normalizeEndpoint (link string) string {
+	parts := strings.Split (endpoint (link), "/")
+	for i, p := range parts {
+		if numericSegment.MatchString (p) { parts[i] = ":id" }
+	}
+	return strings.Join (parts, "/")
+}
+
+// returns what type of error this was, empty if it worked
+func errorClass (ctx context.Context, req *Request, resp *Response, err error) string {
+	if err != nil {
+		if ctx.Err() != nil || errors.Is (err, context.Canceled) { return "canceled" } // the caller gave up
+		if isTimeout (err) { return "timeout" }
+
+		var urlErr *url.Error
+		if errors.As (err, &urlErr) { return "network" }
+		if IsRetryable (err) { return "network" }
+		return "other"
+	}
+
+	if resp == nil { return "" }
+
+	apiErr := resp.Error.Err()
+	if env := envelope (req.Out); apiErr == nil && env != nil { apiErr = env.Error() }
+	if apiErr == nil { return "" } // worked
+
+	switch {
+	case IsAuth (apiErr): return "auth"
+	case IsGone (apiErr): return "gone"
+	case IsNotFound (apiErr): return "not_found"
+	case IsValidation (apiErr): return "validation"
+	case resp.HTTPStatus == http.StatusTooManyRequests: return "rate_limited"
+	case resp.HTTPStatus > 499: return "server"
+	}
+	return "api"
+}
+
+func (this *Metrics) record (ctx context.Context, req *Request, resp *Response, err error, took time.Duration) {
+	key := metricKey { normalizeEndpoint (req.Endpoint), req.Method }
+	status := 0
+	if resp != nil { status = resp.HTTPStatus }
+	class := errorClass (ctx, req, resp, err)
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	m := this.endpoints[key]
+	if m == nil {
+		m = &endpointMetrics { requests: make(map[int]uint64), errors: make(map[string]uint64), buckets: make([]uint64, len(this.buckets)) }
+		this.endpoints[key] = m
+	}
+
+	m.requests[status]++
+	if len(class) > 0 { m.errors[class]++ }
+
+	secs := took.Seconds()
+	for i, b := range this.buckets {
+		if secs <= b {
+			m.buckets[i]++
+			break
+		}
+	}
+	m.sum += secs
+	m.count++
+}
+
+// escapes a label value for the text format
+func escapeLabel (val string) string {
+	return strings.NewReplacer (`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace (val)
+}
+
+func formatFloat (f float64) string {
+	return strconv.FormatFloat (f, 'g', -1, 64)
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- FUNCTIONS -------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// creates a new collector, using DefaultMetricBuckets if no buckets are passed
+func NewMetrics (buckets ...float64) *Metrics {
+	if len(buckets) == 0 { buckets = DefaultMetricBuckets }
+
+	b := append ([]float64{}, buckets...)
+	sort.Float64s (b)
+
+	return &Metrics { buckets: b, endpoints: make(map[metricKey]*endpointMetrics) }
+}
+
+// records metrics for every call made by this ServiceWorks object
+// the same collector can be shared between objects
+func WithMetrics (metrics *Metrics) Option {
+	return func (o *options) {
+		o.metrics = metrics
+		o.middleware = append (o.middleware, metrics.Middleware())
+	}
+}
+
+// returns the collector passed with WithMetrics, or nil
+func (this *ServiceWorks) Metrics () *Metrics {
+	return this.metrics
+}
+
+// returns middleware that records each attempt
+func (this *Metrics) Middleware () Middleware {
+	return func (next Handler) Handler {
+		return func (ctx context.Context, req *Request) (*Response, error) {
+			start := time.Now()
+			resp, err := next (ctx, req)
+			this.record (ctx, req, resp, err, time.Since (start))
+			return resp, err
+		}
+	}
+}
+
+// serves the metrics in the prometheus text format
+func (this *Metrics) ServeHTTP (w http.ResponseWriter, r *http.Request) {
+	w.Header().Set ("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write ([]byte(this.String()))
+}
+
+// returns the metrics in the prometheus text format
+func (this *Metrics) String () string {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	keys := make([]metricKey, 0, len(this.endpoints))
+	for key := range this.endpoints { keys = append (keys, key) }
+	sort.Slice (keys, func (i, j int) bool {
+		if keys[i].endpoint == keys[j].endpoint { return keys[i].method < keys[j].method }
+		return keys[i].endpoint < keys[j].endpoint
+	})
+
+	var b strings.Builder
+	labels := func (key metricKey) string {
+		return fmt.Sprintf (`endpoint="%s",method="%s"`, escapeLabel (key.endpoint), escapeLabel (key.method))
+	}
+
+	b.WriteString ("# HELP serviceworks_requests_total Requests made to serviceworks, by http status code.\n")
+	b.WriteString ("# TYPE serviceworks_requests_total counter\n")
+	for _, key := range keys {
+		m := this.endpoints[key]
+
+		codes := make([]int, 0, len(m.requests))
+		for code := range m.requests { codes = append (codes, code) }
+		sort.Ints (codes)
+
+		for _, code := range codes {
+			fmt.Fprintf (&b, "serviceworks_requests_total{%s,code=\"%d\"} %d\n", labels (key), code, m.requests[code])
+		}
+	}
+
+	b.WriteString ("# HELP serviceworks_errors_total Failed requests to serviceworks, by class of error.\n")
+	b.WriteString ("# TYPE serviceworks_errors_total counter\n")
+	for _, key := range keys {
+		m := this.endpoints[key]
+
+		classes := make([]string, 0, len(m.errors))
+		for class := range m.errors { classes = append (classes, class) }
+		sort.Strings (classes)
+
+		for _, class := range classes {
+			fmt.Fprintf (&b, "serviceworks_errors_total{%s,class=\"%s\"} %d\n", labels (key), class, m.errors[class])
+		}
+	}
+
+	b.WriteString ("# HELP serviceworks_request_duration_seconds Latency of requests to serviceworks.\n")
+	b.WriteString ("# TYPE serviceworks_request_duration_seconds histogram\n")
+	for _, key := range keys {
+		m := this.endpoints[key]
+
+		var total uint64
+		for i, le := range this.buckets {
+			total += m.buckets[i]
+			fmt.Fprintf (&b, "serviceworks_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n", labels (key), formatFloat (le), total)
+		}
+		fmt.Fprintf (&b, "serviceworks_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels (key), m.count)
+		fmt.Fprintf (&b, "serviceworks_request_duration_seconds_sum{%s} %s\n", labels (key), formatFloat (m.sum))
+		fmt.Fprintf (&b, "serviceworks_request_duration_seconds_count{%s} %d\n", labels (key), m.count)
+	}
+
+	return b.String()
+}
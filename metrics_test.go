@@ -0,0 +1,68 @@
+
+package serviceworks 
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/BeelineRoutes/service-works/swtest"
+
+	"testing"
+	"context"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// counts requests and errors per endpoint
+func TestMetrics1 (t *testing.T) {
+	server := swtest.NewServer()
+	defer server.Close()
+
+	metrics := NewMetrics (0.5, 1)
+	sw := New (WithBaseURL (server.URL), WithMetrics (metrics))
+	assert.Equal (t, metrics, sw.Metrics())
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	token := server.Token()
+	sw.SearchCustomers (ctx, token, "nate dogg")
+	sw.SearchCustomers (ctx, token, "someone else")
+	sw.CrewList (ctx, "bad token")
+
+	// serve it
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP (rec, httptest.NewRequest ("GET", "/metrics", nil))
+	body, _ := io.ReadAll (rec.Body)
+	text := string(body)
+
+	assert.Equal (t, true, strings.HasPrefix (rec.Header().Get ("Content-Type"), "text/plain"))
+	assert.Equal (t, true, strings.Contains (text, `serviceworks_requests_total{endpoint="Job/GetCustomerSearch",method="GET",code="200"} 2`))
+	assert.Equal (t, true, strings.Contains (text, `serviceworks_requests_total{endpoint="Configuration/GetUserLists",method="GET",code="200"} 1`))
+	assert.Equal (t, true, strings.Contains (text, `serviceworks_errors_total{endpoint="Configuration/GetUserLists",method="GET",class="auth"} 1`))
+	assert.Equal (t, true, strings.Contains (text, `serviceworks_request_duration_seconds_bucket{endpoint="Job/GetCustomerSearch",method="GET",le="+Inf"} 2`))
+	assert.Equal (t, true, strings.Contains (text, `serviceworks_request_duration_seconds_count{endpoint="Job/GetCustomerSearch",method="GET"} 2`))
+
+	// no query strings in the labels
+	assert.Equal (t, false, strings.Contains (text, "nate"))
+	assert.Equal (t, false, strings.Contains (text, "errors_total{endpoint=\"Job/GetCustomerSearch\""))
+}
+
+func TestMetrics2 (t *testing.T) {
+	assert.Equal (t, "Job/GetJob/:id", normalizeEndpoint ("Job/GetJob/1234?ticketId=5"))
+	assert.Equal (t, `a\"b\\c\n`, escapeLabel ("a\"b\\c\n"))
+}
+
+// timeouts are told apart from the caller giving up
+func TestMetrics3 (t *testing.T) {
+	timeout := &url.Error { Op: "Get", URL: "Configuration/GetUserLists", Err: context.DeadlineExceeded } // what http.Client.Timeout gives us
+	assert.Equal (t, true, IsRetryable (timeout))
+	assert.Equal (t, "timeout", errorClass (context.Background(), &Request{}, nil, timeout))
+
+	ctx, cancel := context.WithCancel (context.Background())
+	cancel()
+	assert.Equal (t, "canceled", errorClass (ctx, &Request{}, nil, timeout))
+	assert.Equal (t, "canceled", errorClass (context.Background(), &Request{}, nil, context.Canceled))
+	assert.Equal (t, false, IsRetryable (context.Canceled))
+}
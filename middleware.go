@@ -0,0 +1,137 @@
+/** ****************************************************************************************************************** **
+	Middleware
+
+	Every attempt at a call goes through a chain of handlers before it's sent, so we can add
+	logging, tracing, metrics or auditing without changing the calls themselves.
+	The first middleware passed is the outermost one.
+
+** ****************************************************************************************************************** **/
+
+package serviceworks
+
+import (
+	"github.com/pkg/errors"
+
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- STRUCTS ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// a single attempt at a call to serviceworks
+type Request struct {
+	Method string
+	Endpoint string // without the query string, ie Job/GetCustomerSearch
+	Header http.Header // changes here are sent with the request
+	Body []byte // the json we're sending, nil for GETs
+	Attempt int // starts at 1, goes up when we retry
+	Out interface{} // where the response is decoded to
+
+	link string // endpoint with the query string
+}
+
+// what we got back from serviceworks
+type Response struct {
+	HTTPStatus int
+	Header http.Header
+	BodySize int64 // bytes read from the response
+
+	// from the ApiStatus in the response, 0 and empty if it didn't have one
+	ApiStatus int
+	ApiMessage string
+
+	Error *Error // set for 4xx and 5xx responses
+	Warnings []SchemaWarning // fields that didn't match what we expected, only set with WithStrictDecoding
+
+	body []byte // kept for strict decoding
+}
+
+// sends the request and returns the response
+// an error means we didn't get a response, or couldn't decode it
+type Handler func (ctx context.Context, req *Request) (*Response, error)
+
+type Middleware func (next Handler) Handler
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- PRIVATE ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// builds our chain of middleware, ending with the actual request
+func (this *ServiceWorks) handler () Handler {
+	h := Handler(this.roundTrip)
+	for i := len(this.middleware) - 1; i >= 0; i-- {
+		h = this.middleware[i](h)
+	}
+
+	// our own limits go outside everything else, so time spent waiting isn't counted as part of the request
+	if this.limiter != nil { h = this.limiter.middleware (h) }
+	if this.breaker != nil { h = this.breaker.middleware (h) } // no point waiting on the limit if we're going to fail
+	return h
+}
+
+// returns the error without anything that could hold pii, like the url's query string
+func safeErr (err error) string {
+	var urlErr *url.Error
+	if errors.As (err, &urlErr) { return urlErr.Err.Error() }
+
+	if apiErr := AsAPIError (err); apiErr != nil { return apiErr.Err.Error() }
+	return errors.Cause(err).Error()
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- FUNCTIONS -------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// returns true if the ApiStatus we decoded says the call failed
+// we go by the same rules as our errors, since a 0 status is how most validation failures come back
+func apiFailed (out interface{}) bool {
+	if d, ok := out.(*doResponse); ok && d.found == false { return false } // Do found nothing to check
+
+	env := envelope (out)
+	return env != nil && env.Error() != nil
+}
+
+// adds middleware to every call, they're run in the order passed
+func WithMiddleware (mw ...Middleware) Option {
+	return func (o *options) { o.middleware = append (o.middleware, mw...) }
+}
+
+// logs every call, with how long it took and what we got back
+// bodies aren't logged, only their size, so there's no pii in the logs
+func Logging (logger *slog.Logger) Middleware {
+	return func (next Handler) Handler {
+		return func (ctx context.Context, req *Request) (*Response, error) {
+			start := time.Now()
+			resp, err := next (ctx, req)
+
+			attrs := []slog.Attr {
+				slog.String ("endpoint", req.Endpoint),
+				slog.String ("method", req.Method),
+				slog.Duration ("duration", time.Since (start)),
+				slog.Int ("attempt", req.Attempt),
+				slog.Int ("request_bytes", len(req.Body)),
+			}
+
+			level := slog.LevelInfo
+			if resp != nil {
+				attrs = append (attrs, slog.Int ("http_status", resp.HTTPStatus), slog.Int ("api_status", resp.ApiStatus),
+								slog.Int64 ("response_bytes", resp.BodySize))
+
+				if resp.Error != nil || apiFailed (req.Out) { level = slog.LevelWarn }
+			}
+
+			if err != nil {
+				level = slog.LevelError
+				attrs = append (attrs, slog.String ("error", safeErr (err)))
+			}
+
+			logger.LogAttrs (ctx, level, "serviceworks request", attrs...)
+			return resp, err
+		}
+	}
+}
@@ -0,0 +1,99 @@
+
+package serviceworks 
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/BeelineRoutes/service-works/swtest"
+
+	"testing"
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// middleware runs in order, and can change the request
+func TestMiddleware1 (t *testing.T) {
+	server := swtest.NewServer()
+	defer server.Close()
+
+	var order []string 
+	mark := func (name string) Middleware {
+		return func (next Handler) Handler {
+			return func (ctx context.Context, req *Request) (*Response, error) {
+				order = append (order, name + " " + req.Endpoint)
+				resp, err := next (ctx, req)
+				order = append (order, name + " done")
+				return resp, err
+			}
+		}
+	}
+
+	// swaps in a valid token, so we know the header change is sent
+	token := server.Token()
+	auth := func (next Handler) Handler {
+		return func (ctx context.Context, req *Request) (*Response, error) {
+			req.Header.Set ("Token", token)
+			return next (ctx, req)
+		}
+	}
+
+	sw := New (WithBaseURL (server.URL), WithMiddleware (mark ("first"), mark ("second")), WithMiddleware (auth))
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	_, err := sw.JobsListTimeRanges (ctx, "not a real token")
+	if err != nil { t.Fatal (err) }
+
+	assert.Equal (t, []string { "first Job/GetTimeRange", "second Job/GetTimeRange", "second done", "first done" }, order)
+}
+
+// logs what happened, without any customer info
+func TestMiddleware2 (t *testing.T) {
+	server := swtest.NewServer()
+	defer server.Close()
+
+	buf := &bytes.Buffer{}
+	logger := slog.New (slog.NewJSONHandler (buf, nil))
+
+	sw := New (WithBaseURL (server.URL), WithMiddleware (Logging (logger)))
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	_, err := sw.SearchCustomers (ctx, server.Token(), "nate dogg")
+	if err != nil { t.Fatal (err) }
+
+	_, err = sw.CrewList (ctx, "bad token")
+	if err == nil { t.Fatal ("we were expecting an error") }
+
+	lines := strings.Split (strings.TrimSpace (buf.String()), "\n")
+	assert.Equal (t, 2, len(lines))
+
+	assert.Equal (t, true, strings.Contains (lines[0], `"level":"INFO"`))
+	assert.Equal (t, true, strings.Contains (lines[0], `"endpoint":"Job/GetCustomerSearch"`))
+	assert.Equal (t, true, strings.Contains (lines[0], `"http_status":200`))
+	assert.Equal (t, true, strings.Contains (lines[0], `"api_status":1`))
+	assert.Equal (t, false, strings.Contains (buf.String(), "nate"))
+
+	assert.Equal (t, true, strings.Contains (lines[1], `"level":"WARN"`))
+	assert.Equal (t, true, strings.Contains (lines[1], `"api_status":2`))
+
+	// validation failures come back with a 0 status
+	buf.Reset()
+	_, err = sw.CreateCustomer (ctx, server.Token(), "", "Dogg", "", "", "", "", "", "", "")
+	if err == nil { t.Fatal ("we were expecting an error") }
+
+	assert.Equal (t, true, strings.Contains (buf.String(), `"level":"WARN"`))
+	assert.Equal (t, true, strings.Contains (buf.String(), `"api_status":0`))
+
+	// but an empty search isn't a failure
+	buf.Reset()
+	_, err = sw.ListJobs (ctx, server.Token(), time.Date (1990, 1, 1, 0, 0, 0, 0, time.UTC), time.Date (1990, 1, 2, 0, 0, 0, 0, time.UTC))
+	if err != nil { t.Fatal (err) }
+
+	assert.Equal (t, true, strings.Contains (buf.String(), `"level":"INFO"`))
+	assert.Equal (t, true, strings.Contains (buf.String(), `"api_status":0`))
+}
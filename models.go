@@ -12,6 +12,7 @@ import (
 	"net/http"
 	"strings"
 	"encoding/json"
+	"time"
 )
 
   //-----------------------------------------------------------------------------------------------------------------------//
@@ -20,6 +21,7 @@ import (
 
 type JobStatus int 
 
+// the statuses we've confirmed from the api, companies have others of their own, see JobStatusSet
 const (
 	JobStatus_unassigned 	JobStatus = 1
 	JobStatus_scheduled		JobStatus = 2
@@ -33,6 +35,11 @@ var (
 	ErrInvalidCode 		= errors.New("Token not valid")
 	ErrInvalidUserPassword	= errors.New("Username or Password is invalid")
 	ErrAuthExpired		= errors.New("Token expired")
+	ErrBadResponse		= errors.New("Bad response") // generic failure, check the APIError for details
+	ErrNotFound			= errors.New("Not found")
+	ErrGone				= errors.New("Resource is gone") // archived or deleted
+	ErrJobGone			= errors.New("Job was archived") // an APIError with this also matches ErrGone
+	ErrResponseTooLarge	= errors.New("Response is larger than the max size") // see WithMaxResponseSize
 )
 
   //-----------------------------------------------------------------------------------------------------------------------//
@@ -43,24 +50,42 @@ type apiStatus struct {
 	Status int 
 	Message string 
 	Errors interface{}
+
+	endpoint string // set once we've received it, so our errors say where they came from
+	httpStatus int 
+	curl string // set if the call failed and WithCurl is on
+	redact *redactor // masks the pii in our error, nil uses the defaults
+	pii []string // values from our request that may be repeated back in the message
 }
 
 func (this *apiStatus) Error () error {
 	if this == nil { return errors.Errorf ("ApiStatus not found") }
 
-	jstr, _ := json.Marshal(this.Errors) // i think this should give me the error object as a string
-
 	// check the response
 	if this.Status == 1 { return nil } // we're good
 
+	apiErr := &APIError {
+		HTTPStatus: this.httpStatus,
+		Status: this.Status,
+		Message: this.Message,
+		Endpoint: this.endpoint,
+		Errors: this.Errors,
+		Fields: decodeFieldErrors (this.Errors),
+		Curl: this.curl,
+		Err: ErrBadResponse, // until we know better
+	}
+	redactorOr (this.redact).apiError (apiErr, this.pii)
+
 	// this means it failed
 	// see if we know why
 	if this.Status == 3 && strings.Contains(this.Message, "Username or Password is not valid") {
-		return errors.WithStack(ErrInvalidUserPassword)
+		apiErr.Err = ErrInvalidUserPassword
+		return errors.WithStack(apiErr)
 	}
 
 	if this.Status == 0 && strings.Contains(this.Message, "Api Exception") {
-		return errors.WithStack(ErrInvalidCode)
+		apiErr.Err = ErrInvalidCode
+		return errors.WithStack(apiErr)
 	}
 
 	if this.Status == 0 && strings.EqualFold(this.Message, "No Jobs Found") {
@@ -68,17 +93,29 @@ func (this *apiStatus) Error () error {
 	}
 
 	if this.Status == 2 && strings.Contains(this.Message, "invalid token") {
-		return errors.WithStack(ErrInvalidCode)
+		apiErr.Err = ErrInvalidCode
+		return errors.WithStack(apiErr)
+	}
+
+	if strings.Contains(strings.ToLower(this.Message), "not found") {
+		apiErr.Err = ErrNotFound // ie "Job not found"
+		return errors.WithStack(apiErr)
 	}
 
-	// we don't know what happened, create a generic error message
-	return errors.Errorf ("Bad response. Got status %d :: message '%s' :: %s", this.Status, this.Message, string(jstr))
+	// we don't know what happened, so it stays a generic bad response
+	return errors.WithStack(apiErr)
 }
 
 //----- ERRORS ---------------------------------------------------------------------------------------------------------//
 type Error struct {
 	ErrMsg, Description string 
 	StatusCode int 
+	Endpoint string 
+
+	retryAfter time.Duration // from the Retry-After header on 429 and 503 responses
+	curl string // set if WithCurl is on
+	redact *redactor // masks the pii in our error, nil uses the defaults
+	pii []string // values from our request that may be repeated back in the message
 }
 
 func (this *Error) UnmarshalJSON (b []byte) error {
@@ -127,34 +164,62 @@ func (this *Error) UnmarshalJSON (b []byte) error {
 
 func (this *Error) Err () error {
 	if this == nil { return nil } // no error
+
+	apiErr := &APIError {
+		HTTPStatus: this.StatusCode,
+		Message: this.ErrMsg,
+		Description: this.Description,
+		Endpoint: this.Endpoint,
+		Curl: this.curl,
+		Err: ErrBadResponse, // just a default
+	}
+	redactorOr (this.redact).apiError (apiErr, this.pii)
 	
 	if this.ErrMsg == "invalid_grant" { // this is for granting access based on the passed code
-		return errors.Wrap (ErrInvalidCode, this.Description)
+		apiErr.Err = ErrInvalidCode
+		return errors.WithStack (apiErr)
 	}
 
 	switch this.StatusCode {
 	case http.StatusUnauthorized:
-		return errors.Wrap (ErrAuthExpired, this.Description) // invalid for another reason, most likely the oauth has been revoked
+		apiErr.Err = ErrAuthExpired // invalid for another reason, most likely the oauth has been revoked
 	
+	case http.StatusNotFound:
+		apiErr.Err = ErrNotFound
+
+	case http.StatusGone:
+		apiErr.Err = ErrGone // job was archived
 	}
-	// just a default
-	return errors.Errorf ("ServiceWorks Error : %d : %s : %s", this.StatusCode, this.ErrMsg, this.Description)
+	return errors.WithStack (apiErr)
 }
 
-func wrapErr (err error, req interface{}, resp interface{}) error {
+func (this *ServiceWorks) wrapErr (err error, req interface{}, resp interface{}) error {
 	if err == nil { return nil }
 
-	// otherwiset create a more useful error
-	jReq, _ := json.Marshal(req)
-    jResp, _ := json.Marshal(resp)
-
-	return errors.Wrapf(err, "%s :: %s", string(jReq), string(jResp))
+	// otherwiset create a more useful error, without any pii in it
+	r := this.redactor()
+	return errors.Wrapf(err, "%s :: %s", r.value(req), r.value(resp))
 }
 
 //----- PUBLIC ---------------------------------------------------------------------------------------------------------//
 
 type ServiceWorks struct {
 	Url string // this is so we can switch between production and a qa endpoint
+
+	baseUrl string // normalized version of Url, set once by New
+	client *http.Client // if nil we fall back to the http.DefaultClient
+	userAgent string 
+	retry *RetryPolicy // if nil, nothing is retried
+	redact *redactor // masks pii in our errors
+	middleware []Middleware
+	metrics *Metrics // nil unless WithMetrics was used
+	limiter *rateLimiter // nil if we're not rate limiting
+	breaker *breaker // nil if we don't have a circuit breaker
+	curlEnabled bool // attach curl commands to our errors
+	dump *dumpWriter // nil unless WithDebugDump was used
+	capture *harRecorder // traffic for HAR exports, see StartCapture
+	maxResponseSize int64 // in bytes, 0 means there's no limit
+	schema *schemaChecker // nil unless WithStrictDecoding was used
 }
 
   //-----------------------------------------------------------------------------------------------------------------------//
@@ -5,18 +5,48 @@ import (
 	
 	// "github.com/stretchr/testify/assert"
 	//"github.com/pkg/errors"
+	"github.com/BeelineRoutes/service-works/swtest"
 
 	"testing"
 	"os"
+	"sync"
 	"encoding/json"
 )
 
 // used for testing so I don't have my actual credentials in the repo
 type testConfig struct {
 	Username, Password, ApiKey, Token string 
+
+	fake bool // true if we're using the swtest server instead of the qa server
+}
+
+// if there's no test.cfg we run against the fake server instead, seeded to match the qa data
+var (
+	fakeOnce sync.Once
+	fakeServer *swtest.Server
+	fakeConfig testConfig
+)
+
+func newFakeServer () {
+	fakeServer = swtest.NewServer()
+	fakeServer.SetCompany (373, "Atlantic Standard Time")
+
+	fakeServer.AddEmployee (swtest.Employee { EmployeeID: 1694, FirstName: "Nate", LastName: "Thomas", IsTechnician: true, IsActive: true })
+	fakeServer.AddCustomer (swtest.Customer { CustomerId: 20144, FirstName: "Nate", LastName: "Dogg", IsActive: true })
+	fakeServer.AddCustomer (swtest.Customer { CustomerId: 20147, FirstName: "Harry", LastName: "Potter", IsActive: true, 
+		Addresses: []swtest.Address {{ AddressLine1: "23 Potter Pl", City: "Shelburne", State: "VT", Zip: "05482", IsActive: true }},
+	})
+	fakeServer.AddJob (swtest.Job { CustomerId: 20147, Duration: 60, IssueDescription: "fix the sink", AssignDateTime: "2023-11-30T10:00:00" })
+
+	fakeConfig = testConfig { Username: swtest.Username, Password: swtest.Password, ApiKey: swtest.ApiKey, Token: fakeServer.Token(), fake: true }
 }
 
 func saveConfig (t *testing.T, cfg *testConfig) {
+	if cfg.fake {
+		fakeConfig = *cfg // just keep it in memory
+		return 
+	}
+
 	jstr, err := json.Marshal(cfg)
 	if err != nil { t.Fatal (err) }
 
@@ -27,6 +57,12 @@ func saveConfig (t *testing.T, cfg *testConfig) {
 func newServiceWorks (t *testing.T) (*ServiceWorks, *testConfig) {
 	// read our local config
 	config, err := os.Open("test.cfg")
+	if os.IsNotExist (err) {
+		fakeOnce.Do (newFakeServer)
+
+		cfg := fakeConfig // copy
+		return New (WithBaseURL (fakeServer.URL)), &cfg
+	}
 	if err != nil { t.Fatal (err) }
 
 	cfg := &testConfig{}
@@ -35,9 +71,7 @@ func newServiceWorks (t *testing.T) (*ServiceWorks, *testConfig) {
 	err = jsonParser.Decode (cfg)
 	if err != nil { t.Fatal (err) }
 
-	sw := &ServiceWorks {
-		Url: "http://65.61.142.55:82", // connect to the qa server by default
-	}
+	sw := New (WithBaseURL ("http://65.61.142.55:82")) // connect to the qa server by default
 
 	return sw, cfg
 }
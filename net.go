@@ -13,53 +13,173 @@ import (
     "net/http"
     "context"
     "encoding/json"
-    "io/ioutil"
+    "io"
+    "compress/gzip"
     "bytes"
-	"strings"
+    "net/url"
+    "strings"
+    "time"
 )
 
   //-----------------------------------------------------------------------------------------------------------------------//
  //----- PRIVATE ---------------------------------------------------------------------------------------------------------//
 //-----------------------------------------------------------------------------------------------------------------------//
 
+// reads the response body, decompressing it if needed and counting what we read
+// fails with ErrResponseTooLarge once we go over the max, so we never hold more than that
+type bodyReader struct {
+	r io.Reader
+	read, max int64 // max of 0 means there's no limit
+	capture *bytes.Buffer // copy of the body for HAR exports, nil if we're not capturing
+}
+
+func (this *bodyReader) Read (p []byte) (int, error) {
+	n, err := this.r.Read (p)
+	this.read += int64(n)
+	if this.capture != nil { this.capture.Write (p[:n]) }
+
+	if this.max > 0 && this.read > this.max { return n, errors.WithStack (ErrResponseTooLarge) }
+	return n, err
+}
+
+func (this *bodyReader) captured () []byte {
+	if this == nil || this.capture == nil { return nil }
+	return this.capture.Bytes()
+}
+
+// wraps the response body, we ask for gzip ourselves so the transport leaves it to us to decompress
+func (this *ServiceWorks) newBodyReader (resp *http.Response) (*bodyReader, error) {
+	ret := &bodyReader { r: resp.Body, max: this.maxResponseSize }
+
+	if strings.EqualFold (resp.Header.Get ("Content-Encoding"), "gzip") {
+		zr, err := gzip.NewReader (resp.Body)
+		if err == io.EOF { 
+			ret.r = bytes.NewReader (nil) // empty body
+		} else if err != nil { 
+			return nil, errors.WithStack (err) 
+		} else {
+			ret.r = zr
+		}
+	}
+
+	if ret.max > 0 { ret.r = io.LimitReader (ret.r, ret.max + 1) } // just enough to know we went over
+	return ret, nil
+}
+
 // handles making the request and reading the results from it 
-// if there's an error the Error object will be set, otherwise it will be nil
-func (this *ServiceWorks) finish (req *http.Request, out interface{}) (*Error, error) {
-	resp, err := http.DefaultClient.Do (req)
+// if there's an error the Error object on the response will be set, otherwise it will be nil
+func (this *ServiceWorks) finish (req *http.Request, out interface{}) (*Response, error) {
+	var reqBody []byte
+	capturing := this.capture.capturing()
+	if capturing && req.GetBody != nil {
+		if b, err := req.GetBody(); err == nil { reqBody, _ = io.ReadAll (b) }
+	}
+
+	start := time.Now()
+	resp, err := this.httpClient().Do (req)
 	
-	if err != nil { return nil, errors.WithStack (err) }
+	if err != nil { 
+		this.captureHAR (req, reqBody, nil, nil, start, err)
+		return nil, errors.WithStack (err) 
+	}
 	defer resp.Body.Close()
 
-	body, _ := ioutil.ReadAll (resp.Body)
+	body, err := this.newBodyReader (resp)
+	if err != nil {
+		this.captureHAR (req, reqBody, nil, nil, start, err)
+		return nil, err
+	}
+	if capturing || this.schema != nil { body.capture = &bytes.Buffer{} }
+
+	ret, err := this.decode (resp, body, out)
+	ret.BodySize = body.read
+	if this.schema != nil { ret.body = body.captured() } // checked in roundTrip
+	
+	if capturing { this.captureHAR (req, reqBody, resp, body.captured(), start, err) }
+	return ret, err
+}
+
+// reads the response into our error object or the caller's object
+func (this *ServiceWorks) decode (resp *http.Response, body *bodyReader, out interface{}) (*Response, error) {
+	ret := &Response {
+		HTTPStatus: resp.StatusCode,
+		Header: resp.Header,
+	}
 
 	if resp.StatusCode == http.StatusGone {
 		// this means that the job/estimate was deleted 
-		errObj := &Error{
+		ret.Error = &Error{
 			StatusCode: resp.StatusCode,
 		}
-		return errObj, nil
+		_, err := io.Copy (io.Discard, body) // so the connection can be reused
+		return ret, err
 
 	} else if resp.StatusCode > 499 { 
 		// 500 level errors seem to not share the same error object
+		b, err := io.ReadAll (body)
+		if errors.Is (err, ErrResponseTooLarge) { return ret, err }
+
 		errObj := &Error{}
-		errObj.ErrMsg = string(body) // dump the whole body in here
-        errObj.StatusCode = resp.StatusCode // if it didn't get an error code, set it
+		errObj.ErrMsg = this.redactor().text (string(b)) // dump the whole body in here
+		errObj.StatusCode = resp.StatusCode // if it didn't get an error code, set it
+		errObj.retryAfter = parseRetryAfter (resp.Header.Get ("Retry-After"))
 		
-        return errObj, nil
+		ret.Error = errObj
+		return ret, nil
 
 	} else if resp.StatusCode > 399 { 
+		b, err := io.ReadAll (body)
+		if errors.Is (err, ErrResponseTooLarge) { return ret, err }
+
 		errObj := &Error{}
-		json.Unmarshal (body, errObj)
+		json.Unmarshal (b, errObj)
+		errObj.Description = this.redactor().text (errObj.Description) // this can be the whole body
 
 		if errObj.StatusCode == 0 {
 			errObj.StatusCode = resp.StatusCode // if it didn't get an error code, set it
 		}
-		return errObj, nil
+		if resp.StatusCode == http.StatusTooManyRequests {
+			errObj.retryAfter = parseRetryAfter (resp.Header.Get ("Retry-After"))
+		}
+
+		ret.Error = errObj
+		return ret, nil
 	}
 	
-	if out != nil { err = errors.WithStack (json.Unmarshal (body, out)) }
+	var err error
+	if out != nil { 
+		// decode as we read, so we're not holding the whole body and the objects at the same time
+		err = json.NewDecoder (body).Decode (out)
+		if err == io.EOF { err = io.ErrUnexpectedEOF } // empty body
+		if err != nil { return ret, errors.WithStack (err) }
+	}
+
+	// read whatever's left, so the connection can be reused
+	if _, err = io.Copy (io.Discard, body); err != nil { return ret, err }
+
+	if env := envelope (out); env != nil { 
+		env.httpStatus = resp.StatusCode 
+		ret.ApiStatus = env.Status
+		ret.ApiMessage = env.Message
+	}
 	
-	return nil, err // we're good
+	return ret, nil // we're good
+}
+
+// the end of the middleware chain, this actually sends the request
+func (this *ServiceWorks) roundTrip (ctx context.Context, req *Request) (*Response, error) {
+	hreq, err := http.NewRequestWithContext (ctx, req.Method, fmt.Sprintf ("%s/%s", this.url(), req.link), bytes.NewBuffer(req.Body))
+	if err != nil { return nil, errors.Wrap (err, req.Endpoint) }
+
+	for key, val := range req.Header { hreq.Header[key] = val }
+	if len(hreq.Header.Get ("Accept-Encoding")) == 0 { hreq.Header.Set ("Accept-Encoding", "gzip") } // we decompress it in finish
+
+	resp, err := this.finish (hreq, req.Out)
+	if this.schema != nil && resp != nil {
+		if err == nil { this.schema.check (ctx, req, resp) }
+		resp.body = nil // don't hold on to it
+	}
+	return resp, err
 }
 
   //-----------------------------------------------------------------------------------------------------------------------//
@@ -78,24 +198,102 @@ func (this *ServiceWorks) send (ctx context.Context, requestType, link string, h
 		header["Content-Type"] = "application/json; charset=utf-8"
 	}
 
-	// make sure we got a url
-	if len(this.Url) == 0 {
-		this.Url = "https://apiapp.service.works/api" // production url
-	} else if strings.HasSuffix (this.Url, "api") == false {
-		if strings.HasSuffix (this.Url, "/") {
-			this.Url += "api"
-		} else {
-			this.Url += "/api"
+	reqHeader := make(http.Header)
+	for key, val := range header { reqHeader.Set (key, val) }
+	if len(this.userAgent) > 0 { reqHeader.Set ("User-Agent", this.userAgent) }
+
+	handler := this.handler()
+
+	var errObj *Error
+	var req *Request
+	for attempt := 1; ; attempt++ {
+		req = &Request {
+			Method: requestType,
+			Endpoint: endpoint (link),
+			Header: reqHeader.Clone(),
+			Body: jstr,
+			Attempt: attempt,
+			Out: out,
+			link: link,
 		}
+
+		var resp *Response
+		resp, err = handler (ctx, req)
+
+		errObj = nil
+		if resp != nil { errObj = resp.Error }
+
+		// see if this is worth trying again
+		if this.retry.allowed (ctx, requestType) == false || attempt >= this.retry.MaxAttempts { break }
+		if retryable (ctx, errObj, err) == false { break }
+		if this.retry.wait (ctx, attempt, errObj) == false { break }
 	}
-	
-	req, err := http.NewRequestWithContext (ctx, requestType, fmt.Sprintf ("%s/%s", this.Url, link), bytes.NewBuffer(jstr))
-	if err != nil { return nil, errors.Wrap (err, link) }
 
-	for key, val := range header { req.Header.Set (key, val) }
-	errObj, err := this.finish (req, out)
-	
-	return errObj, errors.Wrapf (err, " %s : %s", link, string(jstr))
+	// so our errors know where they came from, and what to mask
+	r := this.redactor()
+	pii := r.values (link, jstr)
+
+	if errObj != nil { errObj.Endpoint, errObj.redact, errObj.pii = endpoint (link), r, pii }
+	env := envelope (out)
+	if env != nil { env.endpoint, env.redact, env.pii = endpoint (link), r, pii }
+	if resp, ok := out.(*doResponse); ok && resp.found == false { env = nil } // Do found nothing to check
+
+	// transport errors carry the full url, query string and all
+	var urlErr *url.Error
+	if errors.As (err, &urlErr) { urlErr.URL = r.link (urlErr.URL) }
+
+	err = this.attachCurl (req, errObj, env, err)
+
+	return errObj, errors.Wrapf (err, " %s : %s", r.link (link), r.text (string(jstr)))
+}
+
+// used by Do, decodes the ApiStatus and the caller's object from the same response
+type doResponse struct {
+	ApiStatus apiStatus
+	found bool // true if the response had an ApiStatus
+	out interface{}
+}
+
+func (this *doResponse) UnmarshalJSON (b []byte) error {
+	var env struct {
+		ApiStatus *apiStatus
+		Status *int // login calls have the status at the top level
+		Message string
+		Errors interface{}
+	}
+
+	if json.Unmarshal (b, &env) == nil {
+		if env.ApiStatus != nil {
+			this.ApiStatus.Status, this.ApiStatus.Message, this.ApiStatus.Errors = env.ApiStatus.Status, env.ApiStatus.Message, env.ApiStatus.Errors
+			this.found = true
+		} else if env.Status != nil {
+			this.ApiStatus.Status, this.ApiStatus.Message, this.ApiStatus.Errors = *env.Status, env.Message, env.Errors
+			this.found = true
+		}
+	}
+
+	if this.out == nil { return nil }
+	return json.Unmarshal (b, this.out)
+}
+
+// calls any serviceworks endpoint, for the ones we haven't wrapped yet
+// path is relative to the api, ie "Job/GetTimeRange". The token is sent in the header if it's set.
+// the whole response is decoded into out, and the ApiStatus is checked the same way as our other calls
+func (this *ServiceWorks) Do (ctx context.Context, method, path string, query url.Values, token string, in, out any) error {
+	link := strings.TrimPrefix (strings.TrimPrefix (path, "/"), "api/")
+	if len(query) > 0 { link += "?" + query.Encode() }
+
+	header := make(map[string]string)
+	if len(token) > 0 { header["Token"] = token }
+
+	resp := &doResponse { out: out }
+
+	errObj, err := this.send (ctx, method, link, header, in, resp)
+	if err != nil { return errors.WithStack(err) } // bail
+	if errObj != nil { return errObj.Err() } // something else bad
+
+	if resp.found == false { return nil } // nothing to check
+	return resp.ApiStatus.Error()
 }
 
 func (this *ServiceWorks) defaultHeader (token string) (map[string]string) {
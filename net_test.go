@@ -0,0 +1,113 @@
+
+package serviceworks 
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/BeelineRoutes/service-works/swtest"
+
+	"testing"
+	"context"
+	"compress/gzip"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// calling endpoints through Do
+func TestDo1 (t *testing.T) {
+	server := swtest.NewServer()
+	defer server.Close()
+
+	server.AddCustomer (swtest.Customer { CustomerId: 20147, FirstName: "Harry", LastName: "Potter", 
+		Addresses: []swtest.Address {{ AddressLine1: "23 Potter Pl", City: "Shelburne", State: "VT", Zip: "05482", IsActive: true }},
+	})
+
+	sw := New (WithBaseURL (server.URL))
+	token := server.Token()
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	var resp struct {
+		Data []struct {
+			Address, CityName string 
+		}
+	}
+
+	err := sw.Do (ctx, http.MethodGet, "/api/Job/GetCustomerAddress", url.Values { "customerId": { "20147" } }, token, nil, &resp)
+	if err != nil { t.Fatal (err) }
+
+	assert.Equal (t, 1, len(resp.Data))
+	assert.Equal (t, "Shelburne", resp.Data[0].CityName)
+
+	// the ApiStatus is still checked
+	err = sw.Do (ctx, http.MethodGet, "Job/GetTimeRange", nil, "bad token", nil, nil)
+	assert.Equal (t, true, errors.Is (err, ErrInvalidCode))
+	assert.Equal (t, "Job/GetTimeRange", AsAPIError (err).Endpoint)
+
+	// including the ones at the top level for logins
+	var login struct { Token string }
+	err = sw.Do (ctx, http.MethodPost, "Login/LoginWithKey", nil, "", nil, &login)
+	assert.Equal (t, true, errors.Is (err, ErrInvalidUserPassword))
+
+	// and http errors
+	err = sw.Do (ctx, http.MethodGet, "Job/SomethingNew", nil, token, nil, nil)
+	assert.Equal (t, true, IsNotFound (err))
+}
+
+// gzip responses and the max size
+func TestFinish1 (t *testing.T) {
+	var encoding string
+	body := `{"ApiStatus":{"Status":1,"Message":"Success"},"Data":{"Name":"` + strings.Repeat ("a", 1000) + `"}}`
+
+	server := httptest.NewServer (http.HandlerFunc (func (w http.ResponseWriter, r *http.Request) {
+		encoding = r.Header.Get ("Accept-Encoding")
+
+		if r.URL.Query().Get ("gzip") == "1" {
+			w.Header().Set ("Content-Encoding", "gzip")
+			zw := gzip.NewWriter (w)
+			zw.Write ([]byte(body))
+			zw.Close()
+			return
+		}
+		w.Write ([]byte(body))
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	var resp struct {
+		Data struct { Name string }
+	}
+
+	sizes := make([]int64, 0)
+	sw := New (WithBaseURL (server.URL), WithMiddleware (func (next Handler) Handler {
+		return func (ctx context.Context, req *Request) (*Response, error) {
+			r, err := next (ctx, req)
+			if r != nil { sizes = append (sizes, r.BodySize) }
+			return r, err
+		}
+	}))
+
+	err := sw.Do (ctx, http.MethodGet, "Job/Big", url.Values { "gzip": { "1" } }, "", nil, &resp)
+	assert.Nil (t, err)
+	assert.Equal (t, "gzip", encoding)
+	assert.Equal (t, 1000, len(resp.Data.Name))
+	assert.Equal (t, []int64 { int64(len(body)) }, sizes) // counted after decompressing
+
+	// too big
+	small := New (WithBaseURL (server.URL), WithMaxResponseSize (500))
+	err = small.Do (ctx, http.MethodGet, "Job/Big", nil, "", nil, &resp)
+	assert.Equal (t, true, errors.Is (err, ErrResponseTooLarge))
+
+	err = small.Do (ctx, http.MethodGet, "Job/Big", url.Values { "gzip": { "1" } }, "", nil, &resp)
+	assert.Equal (t, true, errors.Is (err, ErrResponseTooLarge)) // the limit is on the decompressed size
+
+	// but fine without a limit
+	unlimited := New (WithBaseURL (server.URL), WithMaxResponseSize (0))
+	assert.Nil (t, unlimited.Do (ctx, http.MethodGet, "Job/Big", nil, "", nil, &resp))
+}
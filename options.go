@@ -0,0 +1,168 @@
+/** ****************************************************************************************************************** **
+	Constructor and functional options
+
+	New builds a ServiceWorks object that is safe to share between goroutines.
+	The url is normalized once here rather than on every call, and the http.Client can be swapped out
+	so we can control timeouts, proxies and connection pooling per tenant.
+
+** ****************************************************************************************************************** **/
+
+package serviceworks
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- CONSTS ----------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+const defaultUrl = "https://apiapp.service.works/api" // production url
+
+// the largest response we'll read by default, a month of jobs for a big company is well under this
+const DefaultMaxResponseSize = 64 << 20
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- STRUCTS ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// Option changes how a ServiceWorks object is built
+type Option func (*options)
+
+// collects everything before we build the final client
+type options struct {
+	url, userAgent string
+	client *http.Client
+	transport http.RoundTripper
+	timeout time.Duration
+	retry *RetryPolicy
+	redactFields []string // nil means the defaults
+	debug bool
+	middleware []Middleware
+	metrics *Metrics
+	rateLimit, tokenRateLimit *RateLimit
+	breaker *BreakerConfig
+	curl bool
+	dump io.Writer
+	maxResponseSize *int64 // nil means the default
+	strict bool
+	schemaHook SchemaHook
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- PRIVATE ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// makes sure the url ends in /api, defaulting to production if nothing was set
+func normalizeUrl (link string) string {
+	link = strings.TrimSpace (link)
+
+	if len(link) == 0 { return defaultUrl }
+
+	link = strings.TrimSuffix (link, "/")
+	if strings.HasSuffix (link, "api") == false {
+		link += "/api"
+	}
+	return link
+}
+
+// returns the url to use for this call without modifying our object
+func (this *ServiceWorks) url () string {
+	if len(this.baseUrl) > 0 { return this.baseUrl } // set from New
+	return normalizeUrl (this.Url) // someone built the struct directly
+}
+
+func (this *ServiceWorks) httpClient () *http.Client {
+	if this.client != nil { return this.client }
+	return http.DefaultClient
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- OPTIONS ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// sets the api endpoint, used for switching between production and the qa server
+func WithBaseURL (link string) Option {
+	return func (o *options) { o.url = link }
+}
+
+// uses this client for all requests. The client is copied, so timeouts or transports set with other options won't modify yours
+func WithHTTPClient (client *http.Client) Option {
+	return func (o *options) { o.client = client }
+}
+
+// sets the transport on the http client
+func WithTransport (transport http.RoundTripper) Option {
+	return func (o *options) { o.transport = transport }
+}
+
+// sets the User-Agent header on every request
+func WithUserAgent (agent string) Option {
+	return func (o *options) { o.userAgent = agent }
+}
+
+// sets the overall timeout on the http client for each request
+func WithTimeout (timeout time.Duration) Option {
+	return func (o *options) { o.timeout = timeout }
+}
+
+// sets the largest response body we'll read, in bytes after decompressing, bigger ones fail with ErrResponseTooLarge
+// 0 or less turns off the limit
+func WithMaxResponseSize (size int64) Option {
+	return func (o *options) { o.maxResponseSize = &size }
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- FUNCTIONS -------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// creates a new ServiceWorks object, defaulting to the production api
+func New (opts ...Option) *ServiceWorks {
+	o := &options{}
+	for _, opt := range opts { opt (o) }
+
+	// copy the client so we never change one that was passed to us
+	client := &http.Client{}
+	if o.client != nil {
+		c := *o.client
+		client = &c
+	}
+
+	if o.transport != nil { client.Transport = o.transport }
+	if o.timeout > 0 { client.Timeout = o.timeout }
+
+	var limiter *rateLimiter
+	if (o.rateLimit != nil && o.rateLimit.Rate > 0) || (o.tokenRateLimit != nil && o.tokenRateLimit.Rate > 0) {
+		limiter = &rateLimiter { tokens: make(map[string]*bucket) }
+		if o.rateLimit != nil && o.rateLimit.Rate > 0 { limiter.global = newBucket (*o.rateLimit) }
+		if o.tokenRateLimit != nil && o.tokenRateLimit.Rate > 0 { limiter.perToken = o.tokenRateLimit }
+	}
+
+	fields := o.redactFields
+	if fields == nil { fields = DefaultPIIFields }
+
+	ret := &ServiceWorks {
+		Url: o.url,
+		baseUrl: normalizeUrl (o.url),
+		client: client,
+		userAgent: o.userAgent,
+		retry: o.retry,
+		redact: newRedactor (fields, o.debug),
+		middleware: o.middleware,
+		metrics: o.metrics,
+		limiter: limiter,
+		curlEnabled: o.curl,
+		capture: &harRecorder{},
+		maxResponseSize: DefaultMaxResponseSize,
+	}
+
+	if o.strict { ret.schema = &schemaChecker { hook: o.schemaHook, seen: make(map[SchemaWarning]bool) } }
+	if o.maxResponseSize != nil { ret.maxResponseSize = *o.maxResponseSize }
+	if ret.maxResponseSize < 0 { ret.maxResponseSize = 0 }
+	if o.dump != nil { ret.dump = &dumpWriter { w: o.dump } }
+	if o.breaker != nil { ret.breaker = newBreaker (*o.breaker) }
+	return ret
+}
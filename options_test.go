@@ -0,0 +1,52 @@
+
+package serviceworks 
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"testing"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+// url normalization
+func TestOptions1 (t *testing.T) {
+	assert.Equal (t, defaultUrl, normalizeUrl (""))
+	assert.Equal (t, "http://65.61.142.55:82/api", normalizeUrl ("http://65.61.142.55:82"))
+	assert.Equal (t, "http://65.61.142.55:82/api", normalizeUrl ("http://65.61.142.55:82/"))
+	assert.Equal (t, "http://65.61.142.55:82/api", normalizeUrl ("http://65.61.142.55:82/api"))
+
+	// building the struct directly still works, and doesn't modify the url
+	sw := &ServiceWorks { Url: "http://localhost/" }
+	assert.Equal (t, "http://localhost/api", sw.url())
+	assert.Equal (t, "http://localhost/", sw.Url)
+}
+
+// the client passed in shouldn't be modified, and our user agent should get sent
+func TestOptions2 (t *testing.T) {
+	var agent, path string 
+	server := httptest.NewServer (http.HandlerFunc (func (w http.ResponseWriter, r *http.Request) {
+		agent = r.Header.Get ("User-Agent")
+		path = r.URL.Path
+		w.Write ([]byte(`{"ApiStatus":{"Status":1},"Data":[{"Id":"1","Text":"8-10"}]}`))
+	}))
+	defer server.Close()
+
+	client := &http.Client{}
+	sw := New (WithBaseURL (server.URL), WithHTTPClient (client), WithTimeout (time.Second), WithUserAgent ("beeline-test"))
+
+	assert.Equal (t, time.Duration(0), client.Timeout)
+	assert.Equal (t, time.Second, sw.httpClient().Timeout)
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	ranges, err := sw.JobsListTimeRanges (ctx, "token")
+	if err != nil { t.Fatal (err) }
+
+	assert.Equal (t, 1, len(ranges))
+	assert.Equal (t, "beeline-test", agent)
+	assert.Equal (t, "/api/Job/GetTimeRange", path)
+}
@@ -0,0 +1,209 @@
+/** ****************************************************************************************************************** **
+	Client side rate limiting
+
+	Token buckets limit how fast we call serviceworks, both overall and for each company.
+	A company is known from WithCompany, which sessions do for you, otherwise each token gets its own bucket.
+	Calls block until a token is available or the context is done.
+	When serviceworks returns a 429 the bucket halves its rate, then slowly works back up as calls succeed.
+	Buckets that haven't been used in a while are dropped, so refreshed tokens don't pile up.
+
+** ****************************************************************************************************************** **/
+
+package serviceworks
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- CONSTS ----------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// buckets unused for this long are dropped, by then a slowed down bucket would be back to full anyway
+const bucketIdle = time.Minute * 10
+
+type companyKey struct{}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- STRUCTS ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+type RateLimit struct {
+	Rate float64 // requests per second
+	Burst int // how many requests can go at once, defaults to 1
+}
+
+type bucket struct {
+	lock sync.Mutex
+	rate, maxRate, burst float64
+	tokens float64
+	last time.Time
+	used time.Time // when the limiter last handed this out, guarded by the limiter's lock
+}
+
+type rateLimiter struct {
+	global *bucket // nil if not limited
+	perToken *RateLimit // nil if not limited
+
+	lock sync.Mutex
+	tokens map[string]*bucket // by company, or by token if we don't know the company
+	swept time.Time
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- PRIVATE ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+func newBucket (limit RateLimit) *bucket {
+	burst := float64(limit.Burst)
+	if burst < 1 { burst = 1 }
+
+	return &bucket {
+		rate: limit.Rate,
+		maxRate: limit.Rate,
+		burst: burst,
+		tokens: burst, // start full
+		last: time.Now(),
+	}
+}
+
+// adds tokens for the time since we last checked, expects the lock to be held
+func (this *bucket) refill (now time.Time) {
+	this.tokens += now.Sub (this.last).Seconds() * this.rate
+	if this.tokens > this.burst { this.tokens = this.burst }
+	this.last = now
+}
+
+// blocks until we get a token or the context is done
+func (this *bucket) wait (ctx context.Context) error {
+	for {
+		this.lock.Lock()
+		now := time.Now()
+		this.refill (now)
+
+		if this.tokens >= 1 {
+			this.tokens--
+			this.lock.Unlock()
+			return nil
+		}
+
+		delay := time.Duration((1 - this.tokens) / this.rate * float64(time.Second))
+		this.lock.Unlock()
+
+		timer := time.NewTimer (delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+			// try again, someone else may have gotten it first
+		}
+	}
+}
+
+// serviceworks told us to slow down, so halve our rate
+func (this *bucket) slowDown () {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	this.refill (time.Now())
+	this.rate /= 2
+	if floor := this.maxRate / 32; this.rate < floor { this.rate = floor } // never stop completely
+}
+
+// the call worked, so work our way back up to the configured rate
+func (this *bucket) speedUp () {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if this.rate >= this.maxRate { return }
+
+	this.refill (time.Now())
+	this.rate += this.maxRate / 20
+	if this.rate > this.maxRate { this.rate = this.maxRate }
+}
+
+// returns the key for the bucket this call belongs to, empty if there isn't one
+func bucketKey (ctx context.Context, token string) string {
+	if companyId, ok := ctx.Value (companyKey{}).(string); ok && len(companyId) > 0 { return "company:" + companyId }
+	if len(token) > 0 { return "token:" + token }
+	return ""
+}
+
+// drops the buckets nobody has used in a while, expects the lock to be held
+func (this *rateLimiter) sweep (now time.Time) {
+	if now.Sub (this.swept) < bucketIdle { return } // no need to do this every call
+	this.swept = now
+
+	for key, b := range this.tokens {
+		if now.Sub (b.used) >= bucketIdle { delete (this.tokens, key) }
+	}
+}
+
+// returns the bucket for this company or token, creating it if needed
+func (this *rateLimiter) tokenBucket (ctx context.Context, token string) *bucket {
+	key := bucketKey (ctx, token)
+	if this.perToken == nil || len(key) == 0 { return nil }
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	now := time.Now()
+	this.sweep (now)
+
+	b := this.tokens[key]
+	if b == nil {
+		b = newBucket (*this.perToken)
+		this.tokens[key] = b
+	}
+	b.used = now
+	return b
+}
+
+// returns middleware that waits for both buckets before sending
+func (this *rateLimiter) middleware (next Handler) Handler {
+	return func (ctx context.Context, req *Request) (*Response, error) {
+		buckets := make([]*bucket, 0, 2)
+		if this.global != nil { buckets = append (buckets, this.global) }
+		if b := this.tokenBucket (ctx, req.Header.Get ("Token")); b != nil { buckets = append (buckets, b) }
+
+		for _, b := range buckets {
+			if err := b.wait (ctx); err != nil { return nil, err }
+		}
+
+		resp, err := next (ctx, req)
+
+		for _, b := range buckets {
+			if resp != nil && resp.HTTPStatus == http.StatusTooManyRequests {
+				b.slowDown()
+			} else if err == nil {
+				b.speedUp()
+			}
+		}
+		return resp, err
+	}
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- FUNCTIONS -------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// limits how fast this object calls serviceworks, across all tokens
+func WithRateLimit (limit RateLimit) Option {
+	return func (o *options) { o.rateLimit = &limit }
+}
+
+// limits how fast we call serviceworks for each company, so one company can't use up the whole limit
+// calls without WithCompany are limited by their token instead
+func WithTokenRateLimit (limit RateLimit) Option {
+	return func (o *options) { o.tokenRateLimit = &limit }
+}
+
+// returns a context that tells our rate limiter which company this call is for
+// so they share a bucket, and its slow down, across token refreshes. Sessions do this for you
+func WithCompany (ctx context.Context, companyId string) context.Context {
+	return context.WithValue (ctx, companyKey{}, companyId)
+}
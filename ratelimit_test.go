@@ -0,0 +1,138 @@
+
+package serviceworks 
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/pkg/errors"
+	"github.com/BeelineRoutes/service-works/swtest"
+
+	"testing"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+// calls are spaced out by the global limit
+func TestRateLimit1 (t *testing.T) {
+	server := swtest.NewServer()
+	defer server.Close()
+
+	sw := New (WithBaseURL (server.URL), WithRateLimit (RateLimit { Rate: 20, Burst: 1 }))
+	token := server.Token()
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err := sw.JobsListTimeRanges (ctx, token)
+		if err != nil { t.Fatal (err) }
+	}
+
+	assert.Equal (t, true, time.Since (start) >= time.Millisecond * 90) // 2 waits of 50ms, give or take
+
+	// we give up when the context does
+	slow := New (WithBaseURL (server.URL), WithRateLimit (RateLimit { Rate: 0.1 }))
+	_, err := slow.JobsListTimeRanges (ctx, token)
+	if err != nil { t.Fatal (err) }
+
+	short, cancel2 := context.WithTimeout (ctx, time.Millisecond * 50)
+	defer cancel2()
+
+	_, err = slow.JobsListTimeRanges (short, token)
+	assert.Equal (t, context.DeadlineExceeded, errors.Cause (err))
+}
+
+// each token gets its own bucket
+func TestRateLimit2 (t *testing.T) {
+	server := swtest.NewServer()
+	defer server.Close()
+
+	sw := New (WithBaseURL (server.URL), WithTokenRateLimit (RateLimit { Rate: 0.1, Burst: 1 }))
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Second * 5)
+	defer cancel()
+
+	// different tokens don't wait on each other
+	for i := 0; i < 3; i++ {
+		_, err := sw.JobsListTimeRanges (ctx, server.Token())
+		if err != nil { t.Fatal (err) }
+	}
+
+	// the same one does
+	token := server.Token()
+	_, err := sw.JobsListTimeRanges (ctx, token)
+	if err != nil { t.Fatal (err) }
+
+	short, cancel2 := context.WithTimeout (ctx, time.Millisecond * 50)
+	defer cancel2()
+
+	_, err = sw.JobsListTimeRanges (short, token)
+	assert.Equal (t, context.DeadlineExceeded, errors.Cause (err))
+}
+
+// a 429 slows us down, and successes bring us back up
+func TestRateLimit3 (t *testing.T) {
+	throttle := true
+	server := httptest.NewServer (http.HandlerFunc (func (w http.ResponseWriter, r *http.Request) {
+		if throttle {
+			w.WriteHeader (http.StatusTooManyRequests)
+			return 
+		}
+		w.Write ([]byte(`{"ApiStatus":{"Status":1},"Data":[]}`))
+	}))
+	defer server.Close()
+
+	sw := New (WithBaseURL (server.URL), WithRateLimit (RateLimit { Rate: 1000, Burst: 10 }))
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	sw.JobsListTimeRanges (ctx, "token")
+	assert.Equal (t, 500.0, sw.limiter.global.rate)
+
+	throttle = false
+	sw.JobsListTimeRanges (ctx, "token")
+	assert.Equal (t, 550.0, sw.limiter.global.rate)
+}
+
+// a company keeps its bucket, and its slow down, when the token changes
+func TestRateLimit4 (t *testing.T) {
+	throttle := true
+	server := httptest.NewServer (http.HandlerFunc (func (w http.ResponseWriter, r *http.Request) {
+		if throttle {
+			w.WriteHeader (http.StatusTooManyRequests)
+			return 
+		}
+		w.Write ([]byte(`{"ApiStatus":{"Status":1},"Data":[]}`))
+	}))
+	defer server.Close()
+
+	sw := New (WithBaseURL (server.URL), WithTokenRateLimit (RateLimit { Rate: 1000, Burst: 10 }))
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	company := WithCompany (ctx, "373")
+
+	sw.JobsListTimeRanges (company, "old token")
+	throttle = false
+	sw.JobsListTimeRanges (company, "refreshed token")
+
+	assert.Equal (t, 1, len(sw.limiter.tokens))
+	assert.Equal (t, 550.0, sw.limiter.tokens["company:373"].rate)
+
+	// without a company each token is on its own
+	sw.JobsListTimeRanges (ctx, "token")
+	assert.Equal (t, 2, len(sw.limiter.tokens))
+	assert.Equal (t, 1000.0, sw.limiter.tokens["token:token"].rate)
+
+	// idle buckets are dropped
+	sw.limiter.tokens["token:token"].used = time.Now().Add (-bucketIdle)
+	sw.limiter.swept = time.Time{}
+
+	sw.JobsListTimeRanges (company, "refreshed token")
+	assert.Equal (t, 1, len(sw.limiter.tokens))
+	assert.NotNil (t, sw.limiter.tokens["company:373"])
+}
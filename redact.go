@@ -0,0 +1,235 @@
+/** ****************************************************************************************************************** **
+	Redacting PII and secrets
+
+	Errors include the request and response so we know what went wrong, but for calls like CreateCustomer
+	that means names, emails, phone numbers and addresses end up in our logs.
+	By default those fields, and our secrets, are masked everywhere we write them out.
+	Full payloads are only included when debug mode is turned on.
+
+** ****************************************************************************************************************** **/
+
+package serviceworks
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- CONSTS ----------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+const redactedValue = "REDACTED"
+
+// fields that hold customer or employee info
+var DefaultPIIFields = []string {
+	"Email", "PrimaryPhone", "AddressLine1", "AddressLine2", "FirstName", "LastName", "ContactPhone",
+	"Phone", "Address", "CustomerName", "CustomerAddress",
+}
+
+// fields that let someone act as us, these are always masked unless we're in debug mode
+var secretFields = []string { "Token", "Password", "ApiKey" }
+
+// headers that carry credentials, these are always masked when we write out headers, even in debug mode
+var secretHeaders = append ([]string { "Authorization", "Cookie", "Set-Cookie" }, secretFields...)
+
+// catches emails in messages even when they weren't in our request
+var emailPattern = regexp.MustCompile (`[^\s@'"<>(),:;]+@[^\s@'"<>(),:;]+\.[A-Za-z]{2,}`)
+
+// values shorter than this are too likely to match something innocent, like "VT" inside a message
+const minRedactLen = 3
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- STRUCTS ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+type redactor struct {
+	fields map[string]bool // lower case
+	debug bool
+}
+
+var defaultRedactor = newRedactor (DefaultPIIFields, false)
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- PRIVATE ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+func newRedactor (fields []string, debug bool) *redactor {
+	ret := &redactor { fields: make(map[string]bool), debug: debug }
+
+	for _, f := range append (fields, secretFields...) {
+		ret.fields[strings.ToLower (f)] = true
+	}
+	return ret
+}
+
+func (this *ServiceWorks) redactor () *redactor {
+	return redactorOr (this.redact) // redact is nil if someone built the struct directly
+}
+
+// for errors that were built directly
+func redactorOr (r *redactor) *redactor {
+	if r != nil { return r }
+	return defaultRedactor
+}
+
+func secretHeader (key string) bool {
+	for _, s := range secretHeaders {
+		if strings.EqualFold (s, key) { return true }
+	}
+	return false
+}
+
+func (this *redactor) masked (key string) bool {
+	return this.fields[strings.ToLower (key)]
+}
+
+// walks the json and replaces the values for any of our fields
+func (this *redactor) walk (val interface{}) interface{} {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if this.masked (key) {
+				if child != nil { v[key] = redactedValue } // leave nulls, they're useful to know about
+			} else {
+				v[key] = this.walk (child)
+			}
+		}
+	case []interface{}:
+		for i := range v { v[i] = this.walk (v[i]) }
+	}
+	return val
+}
+
+// redacts a json payload, anything that isn't json is returned as is
+func (this *redactor) text (body string) string {
+	if this.debug || len(body) == 0 { return body }
+
+	var val interface{}
+	if json.Unmarshal ([]byte(body), &val) != nil { return body } // not json
+
+	ret, err := json.Marshal (this.walk (val))
+	if err != nil { return body }
+	return string(ret)
+}
+
+// marshals the object and redacts it
+func (this *redactor) value (obj interface{}) string {
+	if obj == nil { return "" }
+
+	jstr, err := json.Marshal (obj)
+	if err != nil { return "" }
+
+	return this.text (string(jstr))
+}
+
+// masks any of our fields in the query string, ie Job/GetCustomerSearch?CustomerName=
+func (this *redactor) link (link string) string {
+	i := strings.Index (link, "?")
+	if this.debug || i < 0 { return link }
+
+	params, err := url.ParseQuery (link[i+1:])
+	if err != nil { return link[:i] } // can't tell what's in there, so don't include it
+
+	for key := range params {
+		if this.masked (key) { params.Set (key, redactedValue) }
+	}
+	return link[:i+1] + params.Encode()
+}
+
+// returns the values of our fields from the request, longest first
+// serviceworks repeats them back in its messages, ie "Email harry@hogwarts.edu is already in use"
+func (this *redactor) values (link string, body []byte) []string {
+	if this.debug { return nil }
+
+	var ret []string
+	add := func (val string) {
+		if len(strings.TrimSpace (val)) >= minRedactLen { ret = append (ret, val) }
+	}
+
+	var collect func (val interface{})
+	collect = func (val interface{}) {
+		switch v := val.(type) {
+		case map[string]interface{}:
+			for key, child := range v {
+				if str, ok := child.(string); ok && this.masked (key) {
+					add (str)
+				} else {
+					collect (child)
+				}
+			}
+		case []interface{}:
+			for _, child := range v { collect (child) }
+		}
+	}
+
+	var val interface{}
+	if len(body) > 0 && json.Unmarshal (body, &val) == nil { collect (val) }
+
+	if i := strings.Index (link, "?"); i >= 0 {
+		params, _ := url.ParseQuery (link[i+1:])
+		for key, list := range params {
+			if this.masked (key) == false { continue }
+			for _, v := range list { add (v) }
+		}
+	}
+
+	sort.Slice (ret, func (i, j int) bool { return len(ret[i]) > len(ret[j]) }) // so a shorter value doesn't break up a longer one
+	return ret
+}
+
+// masks the values and any emails in a message
+func (this *redactor) scrub (msg string, values []string) string {
+	if this.debug || len(msg) == 0 { return msg }
+
+	for _, v := range values { msg = strings.ReplaceAll (msg, v, redactedValue) }
+	return emailPattern.ReplaceAllString (msg, redactedValue)
+}
+
+// returns a copy of the decoded json with each string scrubbed
+func (this *redactor) scrubValue (val interface{}, values []string) interface{} {
+	switch v := val.(type) {
+	case string:
+		return this.scrub (v, values)
+
+	case map[string]interface{}:
+		ret := make(map[string]interface{}, len(v))
+		for key, child := range v { ret[key] = this.scrubValue (child, values) }
+		return ret
+
+	case []interface{}:
+		ret := make([]interface{}, len(v))
+		for i, child := range v { ret[i] = this.scrubValue (child, values) }
+		return ret
+	}
+	return val
+}
+
+// masks the pii serviceworks sent back in an error, values are from our request
+func (this *redactor) apiError (apiErr *APIError, values []string) {
+	if this.debug { return }
+
+	apiErr.Message = this.scrub (apiErr.Message, values)
+	apiErr.Description = this.scrub (apiErr.Description, values)
+	apiErr.Errors = this.scrubValue (apiErr.Errors, values)
+	apiErr.Fields = decodeFieldErrors (apiErr.Errors)
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- FUNCTIONS -------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// masks these json fields in errors and logs instead of DefaultPIIFields
+// Token, Password and ApiKey are always masked
+func WithRedaction (fields ...string) Option {
+	return func (o *options) { o.redactFields = append ([]string{}, fields...) }
+}
+
+// turns off all redaction so errors include the full request and response
+// only use this when debugging, it will put customer info and tokens in your logs
+func WithDebug (debug bool) Option {
+	return func (o *options) { o.debug = debug }
+}
@@ -0,0 +1,125 @@
+
+package serviceworks 
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"testing"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+)
+
+// masking json and links
+func TestRedact1 (t *testing.T) {
+	r := newRedactor (DefaultPIIFields, false)
+
+	assert.Equal (t, `{"Customer":{"CustomerId":20144,"Email":"REDACTED","FirstName":"REDACTED","Phone":null},"Token":"REDACTED"}`, 
+		r.text (`{"Token":"blah#Pink","Customer":{"CustomerId":20144,"FirstName":"Nate","Email":"nate@dogg.com","Phone":null}}`))
+	assert.Equal (t, `[{"lastname":"REDACTED"}]`, r.text (`[{"lastname":"Dogg"}]`))
+	assert.Equal (t, "<html>oops</html>", r.text ("<html>oops</html>"))
+
+	assert.Equal (t, "Job/GetCustomerSearch?CustomerName=REDACTED", r.link ("Job/GetCustomerSearch?CustomerName=nate+dogg"))
+	assert.Equal (t, "Job/GetCustomerAddress?customerId=20147", r.link ("Job/GetCustomerAddress?customerId=20147"))
+
+	// only the secrets
+	r = newRedactor (nil, false)
+	assert.Equal (t, `{"FirstName":"Nate","Password":"REDACTED"}`, r.text (`{"FirstName":"Nate","Password":"asdf"}`))
+
+	// and nothing
+	r = newRedactor (DefaultPIIFields, true)
+	assert.Equal (t, `{"FirstName":"Nate","Password":"asdf"}`, r.text (`{"FirstName":"Nate","Password":"asdf"}`))
+}
+
+// customer info doesn't end up in our errors
+func TestRedact2 (t *testing.T) {
+	server := httptest.NewServer (http.HandlerFunc (func (w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/Job/CreateNewJob":
+			w.Write ([]byte(`{"ApiStatus":{"Status":1},"Jobs":[{"TicketId":5,"Customer":{"FirstName":"Nate","Email":"nate@dogg.com"},"Assignments":[]}]}`))
+		default:
+			w.WriteHeader (http.StatusInternalServerError)
+			w.Write ([]byte(`{"Message":"failed","PrimaryPhone":"8025551234"}`))
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	sw := New (WithBaseURL (server.URL))
+
+	_, err := sw.JobCreate (ctx, "token", "fix the sink", 20144, 60, 1, time.Now(), []int{ 1694 })
+	if err == nil { t.Fatal ("we were expecting an error") }
+
+	assert.Equal (t, true, strings.Contains (err.Error(), "fix the sink"))
+	assert.Equal (t, false, strings.Contains (err.Error(), "nate@dogg.com"))
+
+	_, err = sw.SearchCustomers (ctx, "token", "nate dogg")
+	if err == nil { t.Fatal ("we were expecting an error") }
+
+	assert.Equal (t, false, strings.Contains (err.Error(), "8025551234"))
+
+	// unless we're debugging
+	sw = New (WithBaseURL (server.URL), WithDebug (true))
+
+	_, err = sw.JobCreate (ctx, "token", "fix the sink", 20144, 60, 1, time.Now(), []int{ 1694 })
+	assert.Equal (t, true, strings.Contains (err.Error(), "nate@dogg.com"))
+}
+
+// serviceworks repeats our customer info back in its validation errors
+func TestRedact3 (t *testing.T) {
+	server := httptest.NewServer (http.HandlerFunc (func (w http.ResponseWriter, r *http.Request) {
+		w.Write ([]byte(`{"ApiStatus":{"Status":0,"Message":"Email harry@hogwarts.edu is already in use",` +
+			`"Errors":{"Email":["harry@hogwarts.edu is already in use"],"PrimaryPhone":"8025551234 is not a valid phone"}}}`))
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	sw := New (WithBaseURL (server.URL))
+
+	_, err := sw.CreateCustomer (ctx, "token", "Harry", "Potter", "harry@hogwarts.edu", "8025551234", "4 Privet Dr", "", "05482", "Shelburne", "VT")
+	if err == nil { t.Fatal ("we were expecting an error") }
+
+	assert.Equal (t, false, strings.Contains (err.Error(), "harry@hogwarts.edu"))
+	assert.Equal (t, false, strings.Contains (err.Error(), "8025551234"))
+
+	apiErr := AsAPIError (err)
+	if apiErr == nil { t.Fatal ("expected an APIError") }
+
+	assert.Equal (t, "Email REDACTED is already in use", apiErr.Message)
+	assert.ElementsMatch (t, []FieldError {
+		{ Field: "Email", Message: "REDACTED is already in use" },
+		{ Field: "PrimaryPhone", Message: "REDACTED is not a valid phone" },
+	}, apiErr.Fields)
+
+	// unless we're debugging
+	sw = New (WithBaseURL (server.URL), WithDebug (true))
+
+	_, err = sw.CreateCustomer (ctx, "token", "Harry", "Potter", "harry@hogwarts.edu", "8025551234", "4 Privet Dr", "", "05482", "Shelburne", "VT")
+	assert.Equal (t, true, strings.Contains (err.Error(), "harry@hogwarts.edu"))
+}
+
+// transport errors have the whole url in them
+func TestRedact4 (t *testing.T) {
+	server := httptest.NewServer (http.HandlerFunc (func (w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil { conn.Close() } // hang up without a response
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	sw := New (WithBaseURL (server.URL))
+
+	_, err := sw.SearchCustomers (ctx, "token", "Jane Secretname")
+	if err == nil { t.Fatal ("we were expecting an error") }
+
+	assert.Equal (t, false, strings.Contains (err.Error(), "Secretname"), err.Error())
+	assert.Equal (t, true, strings.Contains (err.Error(), "CustomerName=REDACTED"), err.Error())
+}
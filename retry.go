@@ -0,0 +1,130 @@
+/** ****************************************************************************************************************** **
+	Retrying transient failures
+
+	Network errors, 5xx and 429 responses are retried with an exponential backoff and jitter.
+	Only GETs are retried by default, writes need the caller to opt in with AllowRetry on the context.
+	Retry-After is honored on 429 and 503 responses, and we never sleep past the context deadline.
+
+** ****************************************************************************************************************** **/
+
+package serviceworks
+
+import (
+	"github.com/pkg/errors"
+
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- STRUCTS ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// controls how many times and how quickly we retry a failed request
+type RetryPolicy struct {
+	MaxAttempts int // total attempts including the first one, anything less than 2 means no retries
+	BaseDelay time.Duration // delay before the first retry, doubled each time after
+	MaxDelay time.Duration // caps the backoff, also caps how long we'll wait for a Retry-After
+}
+
+type retryWritesKey struct{}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- PRIVATE ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// how long to wait before the next attempt, attempt starts at 1
+func (this *RetryPolicy) backoff (attempt int) time.Duration {
+	base, max := this.BaseDelay, this.MaxDelay
+	if base <= 0 { base = time.Millisecond * 250 }
+	if max <= 0 { max = time.Second * 30 }
+
+	delay := base
+	for i := 1; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max { delay = max }
+
+	// jitter, so all our workers don't come back at the same time
+	half := delay / 2
+	return half + time.Duration(rand.Int63n (int64(half) + 1))
+}
+
+// returns true if we're allowed to retry this type of request
+func (this *RetryPolicy) allowed (ctx context.Context, method string) bool {
+	if this == nil || this.MaxAttempts < 2 { return false }
+
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+
+	allow, _ := ctx.Value (retryWritesKey{}).(bool)
+	return allow // writes only when the caller asked for it
+}
+
+// returns true if the result of this request is worth trying again
+func retryable (ctx context.Context, errObj *Error, err error) bool {
+	if ctx.Err() != nil { return false } // caller gave up, so should we
+	if errors.Is (err, ErrCircuitOpen) { return false } // it won't close before our backoff is done
+
+	if err == nil { err = errObj.Err() }
+	return IsRetryable (err)
+}
+
+// parses the Retry-After header, which can be in seconds or an http date
+func parseRetryAfter (val string) time.Duration {
+	if len(val) == 0 { return 0 }
+
+	if secs, err := strconv.Atoi (val); err == nil {
+		if secs < 0 { return 0 }
+		return time.Duration(secs) * time.Second
+	}
+
+	if at, err := http.ParseTime (val); err == nil {
+		if d := time.Until (at); d > 0 { return d }
+	}
+	return 0
+}
+
+// sleeps before the next attempt. Returns false if the context would expire before we're done waiting
+func (this *RetryPolicy) wait (ctx context.Context, attempt int, errObj *Error) bool {
+	delay := this.backoff (attempt)
+
+	if errObj != nil && errObj.retryAfter > 0 {
+		delay = errObj.retryAfter // the server told us how long
+		if this.MaxDelay > 0 && delay > this.MaxDelay { return false } // longer than we're willing to wait
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && time.Until (deadline) < delay {
+		return false // we'd run out of time anyway
+	}
+
+	timer := time.NewTimer (delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- FUNCTIONS -------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// retries failed requests using this policy
+func WithRetry (policy RetryPolicy) Option {
+	return func (o *options) { o.retry = &policy }
+}
+
+// returns a context that allows non-GET requests like JobCreate to be retried
+// only use this if you're ok with the possibility of the write happening twice
+func AllowRetry (ctx context.Context) context.Context {
+	return context.WithValue (ctx, retryWritesKey{}, true)
+}
@@ -0,0 +1,131 @@
+
+package serviceworks 
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"testing"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+)
+
+// returns a server that fails the first few calls with the passed status code
+func failingServer (failures int32, status int, retryAfter string) (*httptest.Server, *int32) {
+	calls := new(int32)
+	server := httptest.NewServer (http.HandlerFunc (func (w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32 (calls, 1) <= failures {
+			if len(retryAfter) > 0 { w.Header().Set ("Retry-After", retryAfter) }
+			w.WriteHeader (status)
+			return 
+		}
+		w.Write ([]byte(`{"ApiStatus":{"Status":1},"Data":{"EmployeeList":[{"EmployeeID":1694}]}}`))
+	}))
+	return server, calls
+}
+
+// GETs get retried until they work
+func TestRetry1 (t *testing.T) {
+	server, calls := failingServer (2, http.StatusBadGateway, "")
+	defer server.Close()
+
+	sw := New (WithBaseURL (server.URL), WithRetry (RetryPolicy { MaxAttempts: 3, BaseDelay: time.Millisecond }))
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	crew, err := sw.CrewList (ctx, "token")
+	if err != nil { t.Fatal (err) }
+
+	assert.Equal (t, int32(3), atomic.LoadInt32 (calls))
+	assert.Equal (t, 1694, crew[0].EmployeeID)
+}
+
+// we give up after our max attempts
+func TestRetry2 (t *testing.T) {
+	server, calls := failingServer (5, http.StatusServiceUnavailable, "0")
+	defer server.Close()
+
+	sw := New (WithBaseURL (server.URL), WithRetry (RetryPolicy { MaxAttempts: 2, BaseDelay: time.Millisecond }))
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	_, err := sw.CrewList (ctx, "token")
+	if err == nil { t.Fatal ("we were expecting an error") }
+
+	assert.Equal (t, int32(2), atomic.LoadInt32 (calls))
+}
+
+// writes are only retried when the caller asks for it
+func TestRetry3 (t *testing.T) {
+	server, calls := failingServer (1, http.StatusTooManyRequests, "0")
+	defer server.Close()
+
+	sw := New (WithBaseURL (server.URL), WithRetry (RetryPolicy { MaxAttempts: 3, BaseDelay: time.Millisecond }))
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	err := sw.JobUpdate (ctx, "token", 1, 1, 60, 1, 1, time.Now(), []int{ 1694 })
+	if err == nil { t.Fatal ("we were expecting an error") }
+	assert.Equal (t, int32(1), atomic.LoadInt32 (calls))
+
+	err = sw.JobUpdate (AllowRetry (ctx), "token", 1, 1, 60, 1, 1, time.Now(), []int{ 1694 })
+	if err != nil { t.Fatal (err) }
+	assert.Equal (t, int32(2), atomic.LoadInt32 (calls))
+}
+
+// a Retry-After past our deadline means we stop early
+func TestRetry4 (t *testing.T) {
+	server, calls := failingServer (5, http.StatusServiceUnavailable, "120")
+	defer server.Close()
+
+	sw := New (WithBaseURL (server.URL), WithRetry (RetryPolicy { MaxAttempts: 5, BaseDelay: time.Millisecond }))
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Second * 5)
+	defer cancel()
+
+	start := time.Now()
+	_, err := sw.CrewList (ctx, "token")
+	if err == nil { t.Fatal ("we were expecting an error") }
+
+	assert.Equal (t, int32(1), atomic.LoadInt32 (calls))
+	assert.Equal (t, true, time.Since (start) < time.Second)
+	assert.Equal (t, time.Second * 120, parseRetryAfter ("120"))
+}
+
+// a call that times out on our client is tried again, but not one the caller gave up on
+func TestRetry5 (t *testing.T) {
+	calls := new(int32)
+	server := httptest.NewServer (http.HandlerFunc (func (w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32 (calls, 1) == 1 {
+			time.Sleep (time.Millisecond * 300) // longer than our client will wait
+		}
+		w.Write ([]byte(`{"ApiStatus":{"Status":1},"Data":{"EmployeeList":[{"EmployeeID":1694}]}}`))
+	}))
+	defer server.Close()
+
+	sw := New (WithBaseURL (server.URL), WithTimeout (time.Millisecond * 100), WithRetry (RetryPolicy { MaxAttempts: 3, BaseDelay: time.Millisecond }))
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	crew, err := sw.CrewList (ctx, "token")
+	if err != nil { t.Fatal (err) }
+
+	assert.Equal (t, int32(2), atomic.LoadInt32 (calls))
+	assert.Equal (t, 1694, crew[0].EmployeeID)
+
+	// our own deadline isn't retried
+	atomic.StoreInt32 (calls, 0)
+	short, cancelShort := context.WithTimeout (ctx, time.Millisecond * 50)
+	defer cancelShort()
+
+	sw = New (WithBaseURL (server.URL), WithRetry (RetryPolicy { MaxAttempts: 3, BaseDelay: time.Millisecond }))
+	_, err = sw.CrewList (short, "token")
+	if err == nil { t.Fatal ("we were expecting an error") }
+	assert.Equal (t, int32(1), atomic.LoadInt32 (calls))
+}
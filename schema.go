@@ -0,0 +1,226 @@
+/** ****************************************************************************************************************** **
+	Strict decoding
+
+	Serviceworks changes what it sends without telling anyone, and we only notice when a field comes back empty.
+	In strict mode every successful response is compared against the struct we decode it into,
+	and any fields we don't know about, or fields we expected that weren't sent, are reported as warnings.
+	This buffers each response body, so it's meant for staging rather than production.
+
+** ****************************************************************************************************************** **/
+
+package serviceworks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- CONSTS ----------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+type SchemaWarningKind int
+
+const (
+	UnknownField 	SchemaWarningKind = iota + 1 // serviceworks sent a field we don't have
+	MissingField 	// we have a field serviceworks didn't send
+)
+
+func (this SchemaWarningKind) String () string {
+	switch this {
+	case UnknownField: return "unknown"
+	case MissingField: return "missing"
+	}
+	return "invalid"
+}
+
+var unmarshalerType = reflect.TypeOf ((*json.Unmarshaler)(nil)).Elem()
+
+// json field names by reflect.Type, see jsonFields
+var fieldCache sync.Map
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- STRUCTS ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+type SchemaWarning struct {
+	Endpoint string // normalized like our metrics, ie Job/GetCustomerAddress
+	Field string // path to the field, ie Data.Jobs[].CustomerAddress
+	Kind SchemaWarningKind
+}
+
+func (this SchemaWarning) String () string {
+	return fmt.Sprintf ("%s :: %s field %s", this.Endpoint, this.Kind, this.Field)
+}
+
+// called for each response that had warnings
+type SchemaHook func (ctx context.Context, warnings []SchemaWarning)
+
+// keeps everything we've seen so far
+type schemaChecker struct {
+	hook SchemaHook
+
+	lock sync.Mutex
+	seen map[SchemaWarning]bool
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- PRIVATE ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// returns the json names of the fields on this struct, including the ones from embedded structs
+// every model decode needs these, so they're cached by type. Don't change the map you get back
+func jsonFields (typ reflect.Type) map[string]reflect.StructField {
+	if fields, ok := fieldCache.Load (typ); ok { return fields.(map[string]reflect.StructField) }
+
+	fields, _ := fieldCache.LoadOrStore (typ, structFields (typ))
+	return fields.(map[string]reflect.StructField)
+}
+
+func structFields (typ reflect.Type) map[string]reflect.StructField {
+	ret := make(map[string]reflect.StructField)
+
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+
+		name := f.Name
+		if tag, ok := f.Tag.Lookup ("json"); ok {
+			tagName := strings.Split (tag, ",")[0]
+			if tagName == "-" { continue }
+			if len(tagName) > 0 { name = tagName }
+		}
+
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && f.Type.Implements (unmarshalerType) == false {
+			if _, tagged := f.Tag.Lookup ("json"); tagged == false {
+				for key, sub := range jsonFields (f.Type) { ret[key] = sub } // like respLogin embedding apiStatus
+				continue
+			}
+		}
+
+		if f.IsExported() == false { continue }
+		ret[strings.ToLower (name)] = f // encoding/json doesn't care about case
+	}
+	return ret
+}
+
+// compares the decoded json with the type we put it into
+func compareSchema (typ reflect.Type, val interface{}, path string, found map[string]SchemaWarningKind) {
+	if val == nil { return } // null tells us nothing
+
+	for typ.Kind() == reflect.Ptr { typ = typ.Elem() }
+	if reflect.PtrTo (typ).Implements (unmarshalerType) && hasExtra (typ) == false { return } // decodes itself, so we can't tell what it expects
+
+	join := func (name string) string {
+		if len(path) == 0 { return name }
+		return path + "." + name
+	}
+
+	switch typ.Kind() {
+	case reflect.Struct:
+		obj, ok := val.(map[string]interface{})
+		if ok == false { return }
+
+		fields := jsonFields (typ)
+		sent := make(map[string]bool, len(obj))
+
+		for key, child := range obj {
+			sent[strings.ToLower (key)] = true
+
+			f, ok := fields[strings.ToLower (key)]
+			if ok == false {
+				found[join (key)] = UnknownField
+				continue
+			}
+			compareSchema (f.Type, child, join (f.Name), found)
+		}
+
+		for key, f := range fields {
+			if sent[key] { continue }
+			if f.Type.Kind() == reflect.Interface { continue } // we don't know what to expect anyway
+			if strings.Contains (f.Tag.Get ("json"), ",omitempty") { continue } // only sent sometimes
+			found[join (f.Name)] = MissingField
+		}
+
+	case reflect.Slice, reflect.Array:
+		list, ok := val.([]interface{})
+		if ok == false { return }
+
+		for _, child := range list { compareSchema (typ.Elem(), child, path + "[]", found) }
+
+	case reflect.Map:
+		obj, ok := val.(map[string]interface{})
+		if ok == false { return }
+
+		for _, child := range obj { compareSchema (typ.Elem(), child, join ("*"), found) }
+	}
+}
+
+// returns the warnings for this response body
+func schemaWarnings (link string, out interface{}, body []byte) []SchemaWarning {
+	if out == nil || len(body) == 0 { return nil }
+	if _, ok := out.(*doResponse); ok { return nil } // Do is for partial structs, so everything would be a warning
+
+	var val interface{}
+	if json.Unmarshal (body, &val) != nil { return nil }
+
+	found := make(map[string]SchemaWarningKind)
+	compareSchema (reflect.TypeOf (out), val, "", found)
+
+	ret := make([]SchemaWarning, 0, len(found))
+	for field, kind := range found {
+		ret = append (ret, SchemaWarning { Endpoint: normalizeEndpoint (link), Field: field, Kind: kind })
+	}
+	sort.Slice (ret, func (i, j int) bool { return ret[i].Field < ret[j].Field })
+	return ret
+}
+
+// checks the response we just decoded, expects the body to have been kept
+func (this *schemaChecker) check (ctx context.Context, req *Request, resp *Response) {
+	if resp.Error != nil { return } // error bodies have their own shape
+	if env := envelope (req.Out); env != nil && env.Status != 1 { return } // so do failed calls
+
+	resp.Warnings = schemaWarnings (req.link, req.Out, resp.body)
+	if len(resp.Warnings) == 0 { return }
+
+	this.lock.Lock()
+	for _, w := range resp.Warnings { this.seen[w] = true }
+	this.lock.Unlock()
+
+	if this.hook != nil { this.hook (ctx, resp.Warnings) }
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- FUNCTIONS -------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// turns on strict decoding, warnings are set on each Response and passed to the hook, which can be nil
+// calls made through Do aren't checked
+func WithStrictDecoding (hook SchemaHook) Option {
+	return func (o *options) {
+		o.strict = true
+		o.schemaHook = hook
+	}
+}
+
+// returns every warning we've seen since we were created, sorted by endpoint and field
+// empty if strict decoding is off
+func (this *ServiceWorks) SchemaWarnings () []SchemaWarning {
+	if this.schema == nil { return nil }
+
+	this.schema.lock.Lock()
+	defer this.schema.lock.Unlock()
+
+	ret := make([]SchemaWarning, 0, len(this.schema.seen))
+	for w := range this.schema.seen { ret = append (ret, w) }
+
+	sort.Slice (ret, func (i, j int) bool {
+		if ret[i].Endpoint == ret[j].Endpoint { return ret[i].Field < ret[j].Field }
+		return ret[i].Endpoint < ret[j].Endpoint
+	})
+	return ret
+}
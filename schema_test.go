@@ -0,0 +1,84 @@
+package serviceworks 
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"testing"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+// reporting fields that don't match our structs
+func TestStrictDecoding1 (t *testing.T) {
+	failed := false
+	server := httptest.NewServer (http.HandlerFunc (func (w http.ResponseWriter, r *http.Request) {
+		if failed {
+			w.Write ([]byte(`{"ApiStatus":{"Status":2,"Message":"invalid token"}}`))
+			return
+		}
+		w.Write ([]byte(`{"ApiStatus":{"Status":1,"Message":"Success","Errors":null},"Data":{"EmployeeList":[
+			{"EmployeeID":1694,"FirstName":"Harry","LastName":"Potter","Address":"","Zip":"","CityName":"","State":"","Phone":"",
+			"Email":"","UserId":"","IsTechnician":true,"IsActive":true,"Nickname":"Boy Who Lived"}]}}`))
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	var hooked, seen []SchemaWarning
+	sw := New (WithBaseURL (server.URL), 
+		WithStrictDecoding (func (ctx context.Context, warnings []SchemaWarning) { hooked = append (hooked, warnings...) }),
+		WithMiddleware (func (next Handler) Handler {
+			return func (ctx context.Context, req *Request) (*Response, error) {
+				resp, err := next (ctx, req)
+				if resp != nil { seen = append (seen, resp.Warnings...) }
+				return resp, err
+			}
+		}))
+
+	crew, err := sw.CrewList (ctx, "token")
+	if err != nil { t.Fatal (err) }
+	assert.Equal (t, 1, len(crew))
+
+	expected := []SchemaWarning {
+		{ Endpoint: "Configuration/GetUserLists", Field: "Data.EmployeeList[].Color", Kind: MissingField },
+		{ Endpoint: "Configuration/GetUserLists", Field: "Data.EmployeeList[].Nickname", Kind: UnknownField },
+	}
+	assert.Equal (t, expected, seen)
+	assert.Equal (t, expected, hooked)
+	assert.Equal (t, expected, sw.SchemaWarnings())
+	assert.Equal (t, "Configuration/GetUserLists :: unknown field Data.EmployeeList[].Nickname", expected[1].String())
+
+	// failed calls aren't checked
+	failed = true
+	hooked = nil
+	_, err = sw.CrewList (ctx, "token")
+	assert.NotNil (t, err)
+	assert.Equal (t, 0, len(hooked))
+
+	// and nothing when it's off
+	plain := New (WithBaseURL (server.URL))
+	plain.CrewList (ctx, "token")
+	assert.Equal (t, 0, len(plain.SchemaWarnings()))
+}
+
+func TestCompareSchema1 (t *testing.T) {
+	var out struct {
+		Name string 
+		Count int `json:"total"`
+		Skip string `json:"-"`
+		Tags map[string]struct { Color string }
+		When time.Time // decodes itself
+	}
+
+	body := []byte(`{"name":"a","total":1,"Skip":"x","Tags":{"one":{"Colour":"red"}},"When":"2023-11-30T00:00:00Z"}`)
+	warnings := schemaWarnings ("Job/Thing?id=2", &out, body)
+
+	assert.Equal (t, []SchemaWarning {
+		{ Endpoint: "Job/Thing", Field: "Skip", Kind: UnknownField },
+		{ Endpoint: "Job/Thing", Field: "Tags.*.Color", Kind: MissingField },
+		{ Endpoint: "Job/Thing", Field: "Tags.*.Colour", Kind: UnknownField },
+	}, warnings)
+}
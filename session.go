@@ -0,0 +1,172 @@
+/** ****************************************************************************************************************** **
+    Sessions
+
+    A session is bound to a single company's TokenSource so the token doesn't need to be passed to every call.
+    Tokens rejected by serviceworks are refreshed and the call is replayed once, see UseToken.
+    Each call's context is tagged with the company and its location, see WithCompany and WithLocation,
+    so job times are in the company's zone, and a zone we can't load fails the call.
+    The token taking calls on ServiceWorks still work the same as before.
+
+** ****************************************************************************************************************** **/
+
+package serviceworks
+
+import (
+    "context"
+    "strings"
+    "time"
+)
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- STRUCTS ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+type Session struct {
+    sw *ServiceWorks
+    ts TokenSource
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- FUNCTIONS -------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// returns a session that gets its tokens from this source
+func (this *ServiceWorks) Session (ts TokenSource) *Session {
+    return &Session { sw: this, ts: ts }
+}
+
+// returns the company id from the current login
+func (this *Session) CompanyId (ctx context.Context) (string, error) {
+    login, err := this.ts.Token (ctx)
+    if err != nil { return "", err }
+    return login.CompanyId, nil
+}
+
+// returns the company's timezone name from the current login, this is a windows name like "Atlantic Standard Time"
+func (this *Session) TimeZoneName (ctx context.Context) (string, error) {
+    login, err := this.ts.Token (ctx)
+    if err != nil { return "", err }
+    return login.TimeZoneName, nil
+}
+
+// returns the company's location from the current login, job times are wall clock in this zone
+func (this *Session) Location (ctx context.Context) (*time.Location, error) {
+    login, err := this.ts.Token (ctx)
+    if err != nil { return nil, err }
+    return login.Location()
+}
+
+// gets our login and tags the context with its company and location
+// the rate limiter keeps the same bucket for the company when our token is refreshed, and job times use its location
+// a login without a timezone, like from StaticTokenSource, has no location, one we can't load is an error
+func (this *Session) withCompany (ctx context.Context) (context.Context, *RespLogin, error) {
+    login, err := this.ts.Token (ctx)
+    if err != nil { return ctx, nil, err }
+
+    ctx = WithCompany (ctx, login.CompanyId)
+    if len(strings.TrimSpace (login.TimeZoneName)) == 0 { return ctx, login, nil }
+
+    loc, err := login.Location()
+    if err != nil { return ctx, nil, err }
+    return WithLocation (ctx, loc), login, nil
+}
+
+//----- CREW ------------------------------------------------------------------------------------------------------------//
+
+func (this *Session) CrewList (ctx context.Context) (ret []*Employee, err error) {
+    ctx, login, err := this.withCompany (ctx)
+    if err != nil { return }
+
+    err = useLogin (ctx, this.ts, login, func (token string) (err error) {
+        ret, err = this.sw.CrewList (ctx, token)
+        return
+    })
+    return
+}
+
+//----- CUSTOMERS -------------------------------------------------------------------------------------------------------//
+
+func (this *Session) SearchCustomers (ctx context.Context, search string) (ret []*Customer, err error) {
+    ctx, login, err := this.withCompany (ctx)
+    if err != nil { return }
+
+    err = useLogin (ctx, this.ts, login, func (token string) (err error) {
+        ret, err = this.sw.SearchCustomers (ctx, token, search)
+        return
+    })
+    return
+}
+
+func (this *Session) GetCustomerAddress (ctx context.Context, customerId int) (ret string, err error) {
+    ctx, login, err := this.withCompany (ctx)
+    if err != nil { return }
+
+    err = useLogin (ctx, this.ts, login, func (token string) (err error) {
+        ret, err = this.sw.GetCustomerAddress (ctx, token, customerId)
+        return
+    })
+    return
+}
+
+func (this *Session) CreateCustomer (ctx context.Context, firstName, lastName, email, phone, addr, addr2, zip, city, state string) (ret *Customer, err error) {
+    ctx, login, err := this.withCompany (ctx)
+    if err != nil { return }
+
+    err = useLogin (ctx, this.ts, login, func (token string) (err error) {
+        ret, err = this.sw.CreateCustomer (ctx, token, firstName, lastName, email, phone, addr, addr2, zip, city, state)
+        return
+    })
+    return
+}
+
+//----- JOBS ------------------------------------------------------------------------------------------------------------//
+
+func (this *Session) JobsListTimeRanges (ctx context.Context) (ret []TimeRange, err error) {
+    ctx, login, err := this.withCompany (ctx)
+    if err != nil { return }
+
+    err = useLogin (ctx, this.ts, login, func (token string) (err error) {
+        ret, err = this.sw.JobsListTimeRanges (ctx, token)
+        return
+    })
+    return
+}
+
+func (this *Session) JobCreate (ctx context.Context, issueDesc string, customerId, duration, timeRangeId int, target time.Time,
+                                employeeIds []int) (ret *Job, err error) {
+    ctx, login, err := this.withCompany (ctx) // target is sent in the company's location
+    if err != nil { return nil, err }
+
+    // each half gets its own retry, so a rejected token on the schedule doesn't make a second ticket
+    err = useLogin (ctx, this.ts, login, func (token string) (err error) {
+        ret, err = this.sw.createJob (ctx, token, issueDesc, customerId, duration, timeRangeId, target, employeeIds)
+        return
+    })
+    if err != nil { return nil, err }
+
+    err = UseToken (ctx, this.ts, func (token string) error {
+        return this.sw.JobUpdate (ctx, token, ret.TicketId, ret.TripAssignmentId, duration, timeRangeId, ret.TripNo, target, employeeIds)
+    })
+    return
+}
+
+func (this *Session) JobUpdate (ctx context.Context, ticketId, tripAssignId, duration, timeRangeId, tripNo int, target time.Time, employeeIds []int) error {
+    ctx, login, err := this.withCompany (ctx) // target is sent in the company's location
+    if err != nil { return err }
+
+    return useLogin (ctx, this.ts, login, func (token string) error {
+        return this.sw.JobUpdate (ctx, token, ticketId, tripAssignId, duration, timeRangeId, tripNo, target, employeeIds)
+    })
+}
+
+// the dates are sent as the days they are, the jobs come back with their times in the company's location
+func (this *Session) ListJobs (ctx context.Context, start, finish time.Time) (ret []*Job, err error) {
+    ctx, login, err := this.withCompany (ctx)
+    if err != nil { return }
+
+    err = useLogin (ctx, this.ts, login, func (token string) (err error) {
+        ret, err = this.sw.ListJobs (ctx, token, start, finish)
+        return
+    })
+    return
+}
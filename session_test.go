@@ -0,0 +1,175 @@
+
+package serviceworks 
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"testing"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+// calls through the session without a token, and recovers when the token is rejected
+func TestSession1 (t *testing.T) {
+	fake := &loginServer{}
+	server := httptest.NewServer (fake)
+	defer server.Close()
+
+	sw := New (WithBaseURL (server.URL))
+	session := sw.Session (sw.TokenSource ("user", "pass", "key"))
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	crew, err := session.CrewList (ctx)
+	if err != nil { t.Fatal (err) }
+
+	assert.Equal (t, 1694, crew[0].EmployeeID)
+	assert.Equal (t, 1, fake.logins)
+
+	companyId, err := session.CompanyId (ctx)
+	if err != nil { t.Fatal (err) }
+	assert.Equal (t, "373", companyId)
+
+	zone, err := session.TimeZoneName (ctx)
+	if err != nil { t.Fatal (err) }
+	assert.Equal (t, "Atlantic Standard Time", zone)
+
+	fake.token = "someone-else" // our token is no longer good
+
+	crew, err = session.CrewList (ctx)
+	if err != nil { t.Fatal (err) }
+
+	assert.Equal (t, 1694, crew[0].EmployeeID)
+	assert.Equal (t, 2, fake.logins)
+}
+
+// the token is rejected when we go to save the schedule, which shouldn't make a second job
+type jobServer struct {
+	loginServer
+	creates, schedules int
+}
+
+func (this *jobServer) ServeHTTP (w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/api/Job/CreateNewJob":
+		this.creates++
+		w.Write ([]byte(`{"ApiStatus":{"Status":1},"Jobs":[{"TicketId":55,"Assignments":[{"TripAssignmentId":77,"TripNo":1}]}]}`))
+
+	case "/api/Job/SaveSchedule":
+		this.schedules++
+		if this.schedules == 1 {
+			w.Write ([]byte(`{"ApiStatus":{"Status":0,"Message":"Api Exception"}}`))
+			return
+		}
+		w.Write ([]byte(`{"ApiStatus":{"Status":1}}`))
+
+	default:
+		this.loginServer.ServeHTTP (w, r)
+	}
+}
+
+func TestSession2 (t *testing.T) {
+	fake := &jobServer{}
+	server := httptest.NewServer (fake)
+	defer server.Close()
+
+	sw := New (WithBaseURL (server.URL), WithTokenRateLimit (RateLimit { Rate: 1000, Burst: 10 }))
+	session := sw.Session (sw.TokenSource ("user", "pass", "key"))
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	job, err := session.JobCreate (ctx, "fix the sink", 1, 60, 2, time.Now(), []int{ 1694 })
+	if err != nil { t.Fatal (err) }
+
+	assert.Equal (t, 55, job.TicketId)
+	assert.Equal (t, 1, fake.creates)
+	assert.Equal (t, 2, fake.schedules)
+	assert.Equal (t, 2, fake.logins)
+
+	// both tokens were limited as the same company
+	assert.Equal (t, 1, len(sw.limiter.tokens))
+	assert.NotNil (t, sw.limiter.tokens["company:373"])
+}
+
+// a job needs a crew, and we find out before the ticket is made
+func TestSession3 (t *testing.T) {
+	fake := &jobServer{}
+	server := httptest.NewServer (fake)
+	defer server.Close()
+
+	sw := New (WithBaseURL (server.URL))
+	session := sw.Session (sw.TokenSource ("user", "pass", "key"))
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	_, err := session.JobCreate (ctx, "fix the sink", 1, 60, 2, time.Now(), nil)
+	assert.NotNil (t, err)
+
+	_, err = sw.JobCreate (ctx, "token", "fix the sink", 1, 60, 2, time.Now(), []int{})
+	assert.NotNil (t, err)
+
+	assert.Equal (t, 0, fake.creates)
+	assert.Equal (t, 0, fake.schedules)
+}
+
+// counts how often the session asks for a token
+type countingTokenSource struct {
+	TokenSource
+	tokens int
+}
+
+func (this *countingTokenSource) Token (ctx context.Context) (*RespLogin, error) {
+	this.tokens++
+	return this.TokenSource.Token (ctx)
+}
+
+// every call gets the login once, and a zone we can't load fails them all the same way
+func TestSession4 (t *testing.T) {
+	fake := &loginServer{}
+	server := httptest.NewServer (fake)
+	defer server.Close()
+
+	sw := New (WithBaseURL (server.URL))
+	ts := &countingTokenSource { TokenSource: sw.TokenSource ("user", "pass", "key") }
+	session := sw.Session (ts)
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	_, err := session.CrewList (ctx)
+	if err != nil { t.Fatal (err) }
+	assert.Equal (t, 1, ts.tokens)
+
+	// without a zone there's no location, but the call still works
+	static := &countingTokenSource { TokenSource: StaticTokenSource (fake.token) }
+	_, err = sw.Session (static).CrewList (ctx)
+	if err != nil { t.Fatal (err) }
+	assert.Equal (t, 1, static.tokens)
+
+	// a zone we can't load
+	bad := sw.Session (&badZoneTokenSource { TokenSource: StaticTokenSource (fake.token) })
+
+	_, err = bad.CrewList (ctx)
+	assert.Equal (t, true, errors.Is (err, ErrUnknownTimeZone))
+
+	_, err = bad.ListJobs (ctx, time.Now(), time.Now().Add (time.Hour))
+	assert.Equal (t, true, errors.Is (err, ErrUnknownTimeZone))
+}
+
+type badZoneTokenSource struct {
+	TokenSource
+}
+
+func (this *badZoneTokenSource) Token (ctx context.Context) (*RespLogin, error) {
+	login, err := this.TokenSource.Token (ctx)
+	if err != nil { return nil, err }
+
+	login.TimeZoneName = "Hogwarts Standard Time"
+	return login, nil
+}
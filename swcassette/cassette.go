@@ -0,0 +1,301 @@
+/** ****************************************************************************************************************** **
+	Record and replay of serviceworks traffic
+
+	A Cassette is an http.RoundTripper, pass it to serviceworks.WithTransport.
+	In record mode every request is sent to the real server and the pair is appended to a JSONL file.
+	In replay mode requests are answered from that file, matched on the method, path and query.
+	Passthrough just sends everything on, so the same setup can be switched with a flag.
+
+	The Token, Password and ApiKey headers and json fields are redacted before anything is written to disk.
+
+** ****************************************************************************************************************** **/
+
+package swcassette
+
+import (
+	"github.com/pkg/errors"
+
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- CONSTS ----------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+type Mode int
+
+const (
+	ModeReplay 		Mode = iota
+	ModeRecord
+	ModePassthrough
+)
+
+const redacted = "REDACTED"
+
+// headers and json fields we never write to disk
+var secrets = []string { "Token", "Password", "ApiKey" }
+
+var ErrNoInteraction = errors.New("No recorded interaction for this request")
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- STRUCTS ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+type Request struct {
+	Method, Path, Query string
+	Header http.Header
+	Body string
+}
+
+type Response struct {
+	StatusCode int
+	Header http.Header
+	Body string
+}
+
+// a single line in the cassette file
+type Interaction struct {
+	Request Request
+	Response Response
+}
+
+type Cassette struct {
+	Transport http.RoundTripper // used when recording or passing through, defaults to http.DefaultTransport
+
+	mode Mode
+	path string
+
+	lock sync.Mutex
+	interactions []*Interaction
+	used []bool
+	file *os.File
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- PRIVATE ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+func isSecret (key string) bool {
+	for _, s := range secrets {
+		if strings.EqualFold (s, key) { return true }
+	}
+	return false
+}
+
+func redactHeader (header http.Header) http.Header {
+	ret := header.Clone()
+	for key := range ret {
+		if isSecret (key) { ret[key] = []string { redacted } }
+	}
+	return ret
+}
+
+// walks the json and replaces any secret fields
+func redactValue (val interface{}) interface{} {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if isSecret (key) {
+				v[key] = redacted
+			} else {
+				v[key] = redactValue (child)
+			}
+		}
+	case []interface{}:
+		for i := range v { v[i] = redactValue (v[i]) }
+	}
+	return val
+}
+
+// redacts the body if it's json, otherwise it's returned as is
+func redactBody (body []byte) string {
+	var val interface{}
+	if len(body) == 0 || json.Unmarshal (body, &val) != nil { return string(body) }
+
+	ret, err := json.Marshal (redactValue (val))
+	if err != nil { return string(body) }
+	return string(ret)
+}
+
+func (this *Cassette) transport () http.RoundTripper {
+	if this.Transport != nil { return this.Transport }
+	return http.DefaultTransport
+}
+
+// finds the first unused interaction matching this request, once they're all used we keep returning the last one
+func (this *Cassette) find (req *http.Request) *Interaction {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	var last *Interaction
+	for i, in := range this.interactions {
+		if in.Request.Method != req.Method || in.Request.Path != req.URL.Path || in.Request.Query != req.URL.RawQuery { continue }
+
+		last = in
+		if this.used[i] == false {
+			this.used[i] = true
+			return in
+		}
+	}
+	return last
+}
+
+func (this *Cassette) replay (req *http.Request) (*http.Response, error) {
+	in := this.find (req)
+	if in == nil { return nil, errors.Wrapf (ErrNoInteraction, "%s %s?%s", req.Method, req.URL.Path, req.URL.RawQuery) }
+
+	header := in.Response.Header.Clone()
+	if header == nil { header = make(http.Header) }
+
+	return &http.Response {
+		Status: http.StatusText (in.Response.StatusCode),
+		StatusCode: in.Response.StatusCode,
+		Proto: "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header: header,
+		Body: io.NopCloser (strings.NewReader (in.Response.Body)),
+		ContentLength: int64(len(in.Response.Body)),
+		Request: req,
+	}, nil
+}
+
+func (this *Cassette) record (req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = io.ReadAll (req.Body)
+		req.Body.Close()
+		if err != nil { return nil, errors.WithStack (err) }
+
+		req.Body = io.NopCloser (bytes.NewReader (reqBody))
+	}
+
+	resp, err := this.transport().RoundTrip (req)
+	if err != nil { return nil, err } // nothing to record
+
+	respBody, err := io.ReadAll (resp.Body)
+	resp.Body.Close()
+	if err != nil { return nil, errors.WithStack (err) }
+
+	// store it decompressed so we can redact it and read it
+	if strings.EqualFold (resp.Header.Get ("Content-Encoding"), "gzip") {
+		zr, err := gzip.NewReader (bytes.NewReader (respBody))
+		if err != nil { return nil, errors.WithStack (err) }
+
+		respBody, err = io.ReadAll (zr)
+		if err != nil { return nil, errors.WithStack (err) }
+
+		resp.Header.Del ("Content-Encoding")
+		resp.Header.Del ("Content-Length")
+		resp.ContentLength = int64(len(respBody))
+		resp.Uncompressed = true
+	}
+	resp.Body = io.NopCloser (bytes.NewReader (respBody))
+
+	in := &Interaction {
+		Request: Request {
+			Method: req.Method,
+			Path: req.URL.Path,
+			Query: req.URL.RawQuery,
+			Header: redactHeader (req.Header),
+			Body: redactBody (reqBody),
+		},
+		Response: Response {
+			StatusCode: resp.StatusCode,
+			Header: redactHeader (resp.Header),
+			Body: redactBody (respBody),
+		},
+	}
+
+	line, err := json.Marshal (in)
+	if err != nil { return nil, errors.WithStack (err) }
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	this.interactions = append (this.interactions, in)
+	this.used = append (this.used, true)
+
+	_, err = this.file.Write (append (line, '\n'))
+	return resp, errors.WithStack (err)
+}
+
+// reads all the interactions from the file
+func (this *Cassette) load () error {
+	file, err := os.Open (this.path)
+	if err != nil { return errors.WithStack (err) }
+	defer file.Close()
+
+	scanner := bufio.NewScanner (file)
+	scanner.Buffer (make([]byte, 64 * 1024), 64 * 1024 * 1024) // some job lists can be big
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace (scanner.Bytes())
+		if len(line) == 0 { continue }
+
+		in := &Interaction{}
+		if err := json.Unmarshal (line, in); err != nil { return errors.Wrap (err, this.path) }
+
+		this.interactions = append (this.interactions, in)
+	}
+	this.used = make([]bool, len(this.interactions))
+
+	return errors.WithStack (scanner.Err())
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- FUNCTIONS -------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// opens the cassette at this path
+// replay mode reads the existing file, record mode starts a new one
+func New (path string, mode Mode) (*Cassette, error) {
+	ret := &Cassette { mode: mode, path: path }
+
+	switch mode {
+	case ModeReplay:
+		if err := ret.load(); err != nil { return nil, err }
+
+	case ModeRecord:
+		file, err := os.OpenFile (path, os.O_CREATE | os.O_TRUNC | os.O_WRONLY, 0600)
+		if err != nil { return nil, errors.WithStack (err) }
+		ret.file = file
+	}
+
+	return ret, nil
+}
+
+func (this *Cassette) RoundTrip (req *http.Request) (*http.Response, error) {
+	switch this.mode {
+	case ModeReplay:
+		return this.replay (req)
+	case ModeRecord:
+		return this.record (req)
+	}
+	return this.transport().RoundTrip (req)
+}
+
+// returns the interactions we've loaded or recorded
+func (this *Cassette) Interactions () []Interaction {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	ret := make([]Interaction, len(this.interactions))
+	for i, in := range this.interactions { ret[i] = *in }
+	return ret
+}
+
+// closes the file when recording
+func (this *Cassette) Close () error {
+	if this.file == nil { return nil }
+	return errors.WithStack (this.file.Close())
+}
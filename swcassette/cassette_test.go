@@ -0,0 +1,66 @@
+
+package swcassette
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/pkg/errors"
+	serviceworks "github.com/BeelineRoutes/service-works"
+	"github.com/BeelineRoutes/service-works/swtest"
+
+	"testing"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// records against the fake server, then replays it once the server is gone
+func TestCassette1 (t *testing.T) {
+	server := swtest.NewServer()
+	server.AddEmployee (swtest.Employee { EmployeeID: 1694, FirstName: "Nate" })
+
+	path := filepath.Join (t.TempDir(), "crew.jsonl")
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	// record
+	tape, err := New (path, ModeRecord)
+	if err != nil { t.Fatal (err) }
+
+	sw := serviceworks.New (serviceworks.WithBaseURL (server.URL), serviceworks.WithTransport (tape))
+
+	login, err := sw.Login (ctx, swtest.Username, swtest.Password, swtest.ApiKey)
+	if err != nil { t.Fatal (err) }
+
+	crew, err := sw.CrewList (ctx, login.Token)
+	if err != nil { t.Fatal (err) }
+	assert.Equal (t, 1694, crew[0].EmployeeID)
+
+	tape.Close()
+	server.Close()
+
+	// nothing secret should have been written
+	data, err := os.ReadFile (path)
+	if err != nil { t.Fatal (err) }
+
+	assert.Equal (t, 2, strings.Count (string(data), "\n"))
+	assert.Equal (t, false, strings.Contains (string(data), login.Token))
+	assert.Equal (t, false, strings.Contains (string(data), swtest.Password))
+	assert.Equal (t, false, strings.Contains (string(data), swtest.ApiKey))
+
+	// replay, the token doesn't matter
+	tape, err = New (path, ModeReplay)
+	if err != nil { t.Fatal (err) }
+
+	sw = serviceworks.New (serviceworks.WithBaseURL (server.URL), serviceworks.WithTransport (tape))
+
+	crew, err = sw.CrewList (ctx, "some other token")
+	if err != nil { t.Fatal (err) }
+	assert.Equal (t, 1694, crew[0].EmployeeID)
+
+	// and we didn't record this one
+	_, err = sw.JobsListTimeRanges (ctx, "token")
+	assert.Equal (t, true, errors.Is (err, ErrNoInteraction))
+}
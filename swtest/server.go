@@ -0,0 +1,608 @@
+/** ****************************************************************************************************************** **
+	Fake ServiceWorks server for tests
+
+	Starts an httptest.Server that emulates the serviceworks endpoints we use, keeping everything in memory.
+	It reproduces the quirks of the real api, the ApiStatus envelope, the "No Customer Found" row
+	when a search comes back empty, "No Jobs Found" for an empty job search, and 410 for archived jobs.
+
+	This package doesn't import serviceworks, so it can be used from that package's own tests.
+
+** ****************************************************************************************************************** **/
+
+package swtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- CONSTS ----------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// the only credentials the fake server accepts
+const (
+	Username 	= "swtest"
+	Password 	= "swtest-password"
+	ApiKey 		= "swtest-apikey"
+)
+
+const (
+	dateFormat 		= "01/02/2006" // used in query params
+	sendFormat 		= "01/02/2006 15:04:05" // what we get sent when scheduling
+	returnFormat 	= "2006-01-02T15:04:05" // what we send back
+)
+
+// job statuses, these match serviceworks.JobStatus
+const (
+	statusUnassigned 	= 1
+	statusScheduled 	= 2
+	statusUnscheduled 	= 7
+	statusConfirmed 	= 13
+)
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- STRUCTS ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+type Employee struct {
+	EmployeeID int
+	FirstName, LastName, Address, Zip, CityName, State, Phone, Email, UserId, Color string
+	IsTechnician, IsActive bool
+}
+
+type Address struct {
+	AddressId, Type int
+	AddressLine1, AddressLine2, Zip, City, State, Lat, Long, FirstName, LastName, PrimaryPhone, Email string
+	NotifyEmail, NotifyPrimaryPhone bool
+	IsActive bool
+}
+
+// the single line version used for jobs
+func (this Address) human () string {
+	return strings.TrimSpace (fmt.Sprintf ("%s %s %s, %s %s", this.AddressLine1, this.AddressLine2, this.City, this.State, this.Zip))
+}
+
+type Customer struct {
+	FirstName, LastName, CompanyName, Email, PrimaryPhone string
+	CustomerId int
+	IsActive bool
+
+	Addresses []Address
+}
+
+type TimeRange struct {
+	Id, Text string
+}
+
+type Job struct {
+	TicketStatusId, TicketId, Duration int
+	IssueDescription, TicketStatus string
+
+	TripAssignmentId, TripNo, TimeRangeId int
+	AssignDateTime, AssignTime, Team, TeamIds string
+
+	CustomerId int
+	CustomerName, CustomerAddress, ContactPhone string
+
+	archived bool
+}
+
+type apiStatus struct {
+	Status int
+	Message string
+	Errors interface{}
+}
+
+// the fake server, everything is guarded by the lock
+type Server struct {
+	*httptest.Server
+
+	lock sync.Mutex
+	companyId int
+	timeZoneName string
+	tokens map[string]bool
+	tokenCount, nextId int
+
+	employees []*Employee
+	customers []*Customer
+	jobs []*Job
+	timeRanges []TimeRange
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- PRIVATE ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+func writeJSON (w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set ("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader (status)
+	json.NewEncoder(w).Encode (body)
+}
+
+// the wrapper most endpoints use
+func writeData (w http.ResponseWriter, key string, data interface{}) {
+	writeJSON (w, http.StatusOK, map[string]interface{} {
+		"ApiStatus": apiStatus { Status: 1, Message: "Success" },
+		key: data,
+	})
+}
+
+func writeStatus (w http.ResponseWriter, status int, msg string) {
+	writeJSON (w, http.StatusOK, map[string]interface{} {
+		"ApiStatus": apiStatus { Status: status, Message: msg },
+	})
+}
+
+// returns the next id, expects the lock to be held
+func (this *Server) id () int {
+	this.nextId++
+	return this.nextId
+}
+
+// makes sure we never hand out an id that was set by hand, expects the lock to be held
+func (this *Server) seen (id int) {
+	if id > this.nextId { this.nextId = id }
+}
+
+// expects the lock to be held
+func (this *Server) newToken () string {
+	this.tokenCount++
+	token := fmt.Sprintf ("swtest-token-%d", this.tokenCount)
+	this.tokens[token] = true
+	return token
+}
+
+// expects the lock to be held
+func (this *Server) customer (id int) *Customer {
+	for _, c := range this.customers {
+		if c.CustomerId == id { return c }
+	}
+	return nil
+}
+
+// expects the lock to be held
+func (this *Server) job (ticketId int) *Job {
+	for _, j := range this.jobs {
+		if j.TicketId == ticketId { return j }
+	}
+	return nil
+}
+
+// fills in the customer info on the job, expects the lock to be held
+func (this *Server) fillCustomer (j *Job) {
+	c := this.customer (j.CustomerId)
+	if c == nil { return }
+
+	if len(j.CustomerName) == 0 { j.CustomerName = strings.TrimSpace (c.FirstName + " " + c.LastName) }
+	if len(j.ContactPhone) == 0 { j.ContactPhone = c.PrimaryPhone }
+	if len(j.CustomerAddress) == 0 && len(c.Addresses) > 0 { j.CustomerAddress = c.Addresses[0].human() }
+}
+
+func (this *Server) login (w http.ResponseWriter, token string) {
+	writeJSON (w, http.StatusOK, map[string]interface{} {
+		"Status": 1,
+		"Message": "Success",
+		"Companyid": this.companyId,
+		"Token": token,
+		"Phone": "5555555555",
+		"PhoneCode": "+1",
+		"Email": "swtest@example.com",
+		"TimeZoneName": this.timeZoneName,
+	})
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- HANDLERS --------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+func (this *Server) ServeHTTP (w http.ResponseWriter, r *http.Request) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	path := strings.TrimPrefix (r.URL.Path, "/api/")
+
+	switch path {
+	case "Login/LoginWithKey":
+		if r.Header.Get ("UserName") != Username || r.Header.Get ("Password") != Password {
+			writeJSON (w, http.StatusOK, apiStatus { Status: 3, Message: "Username or Password is not valid" })
+			return
+		}
+		if r.Header.Get ("ApiKey") != ApiKey {
+			writeJSON (w, http.StatusOK, apiStatus { Status: 0, Message: "Api Exception" })
+			return
+		}
+		this.login (w, this.newToken())
+		return
+
+	case "Login/RefreshToken":
+		if this.tokens[r.Header.Get ("Token")] == false {
+			writeJSON (w, http.StatusOK, apiStatus { Status: 2, Message: "invalid token" })
+			return
+		}
+		delete (this.tokens, r.Header.Get ("Token")) // the old one is done
+		this.login (w, this.newToken())
+		return
+	}
+
+	// everything else needs a valid token
+	if this.tokens[r.Header.Get ("Token")] == false {
+		writeStatus (w, 2, "invalid token")
+		return
+	}
+
+	switch path {
+	case "Configuration/GetUserLists":
+		writeData (w, "Data", map[string]interface{} { "EmployeeList": this.employees })
+
+	case "Job/GetCustomerSearch":
+		this.customerSearch (w, r)
+
+	case "Job/GetCustomerAddress":
+		this.customerAddress (w, r)
+
+	case "Job/AddEditCustomerDetail":
+		this.addCustomer (w, r)
+
+	case "Job/GetTimeRange":
+		writeData (w, "Data", this.timeRanges)
+
+	case "Job/CreateNewJob":
+		this.createJob (w, r)
+
+	case "Job/SaveSchedule":
+		this.saveSchedule (w, r)
+
+	case "Job/GetApiJobForSearch":
+		this.jobSearch (w, r)
+
+	default:
+		http.NotFound (w, r)
+	}
+}
+
+func (this *Server) customerSearch (w http.ResponseWriter, r *http.Request) {
+	search := strings.ToLower (strings.TrimSpace (r.URL.Query().Get ("CustomerName")))
+
+	found := make([]*Customer, 0)
+	for _, c := range this.customers {
+		name := strings.ToLower (c.FirstName + " " + c.LastName + " " + c.CompanyName)
+		if len(search) > 0 && strings.Contains (name, search) {
+			found = append (found, c)
+		}
+	}
+
+	if len(found) == 0 {
+		// this is what serviceworks does instead of an empty list
+		found = append (found, &Customer { FirstName: "No Customer Found" })
+	}
+
+	writeData (w, "Data", map[string]interface{} { "Customers": found })
+}
+
+func (this *Server) customerAddress (w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi (r.URL.Query().Get ("customerId"))
+
+	type addressGet struct {
+		Address, AddressLine2, CityName, StateName, Zip string
+		IsActive bool
+	}
+	ret := make([]addressGet, 0)
+
+	if c := this.customer (id); c != nil {
+		for _, a := range c.Addresses {
+			ret = append (ret, addressGet { a.AddressLine1, a.AddressLine2, a.City, a.State, a.Zip, a.IsActive })
+		}
+	}
+
+	writeData (w, "Data", ret)
+}
+
+func (this *Server) addCustomer (w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		FirstName, LastName, CustomerType, Email, PrimaryPhone string
+		CustomerId int
+		Address Address
+	}
+
+	if err := json.NewDecoder(r.Body).Decode (&req); err != nil {
+		writeJSON (w, http.StatusBadRequest, map[string]interface{} { "Error": err.Error(), "error_description": "invalid json" })
+		return
+	}
+
+	if len(req.FirstName) == 0 {
+		writeJSON (w, http.StatusOK, map[string]interface{} {
+			"ApiStatus": apiStatus { Status: 0, Message: "Validation Failed", Errors: map[string]string { "FirstName": "First Name is required" } },
+		})
+		return
+	}
+
+	c := &Customer {
+		FirstName: req.FirstName,
+		LastName: req.LastName,
+		Email: req.Email,
+		PrimaryPhone: req.PrimaryPhone,
+		CustomerId: this.id(),
+		IsActive: true,
+	}
+
+	a := req.Address
+	a.AddressId = this.id()
+	a.IsActive = true
+	c.Addresses = append (c.Addresses, a)
+
+	this.customers = append (this.customers, c)
+	writeData (w, "Data", map[string]interface{} { "Customers": []*Customer { c } })
+}
+
+func (this *Server) createJob (w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		CustomerId, Duration, TimeRangeId int
+		IssueDescription, AssignDateTime, AssignTime string
+	}
+
+	if err := json.NewDecoder(r.Body).Decode (&req); err != nil {
+		writeJSON (w, http.StatusBadRequest, map[string]interface{} { "Error": err.Error(), "error_description": "invalid json" })
+		return
+	}
+
+	c := this.customer (req.CustomerId)
+	if c == nil {
+		writeJSON (w, http.StatusOK, map[string]interface{} {
+			"ApiStatus": apiStatus { Status: 0, Message: "Validation Failed", Errors: map[string]string { "CustomerId": "Customer not found" } },
+		})
+		return
+	}
+
+	target, err := time.Parse (sendFormat, req.AssignDateTime)
+	if err != nil {
+		writeJSON (w, http.StatusOK, map[string]interface{} {
+			"ApiStatus": apiStatus { Status: 0, Message: "Validation Failed", Errors: map[string]string { "AssignDateTime": "Invalid date" } },
+		})
+		return
+	}
+
+	j := &Job {
+		TicketStatusId: statusUnassigned,
+		TicketStatus: "Unassigned",
+		TicketId: this.id(),
+		Duration: req.Duration,
+		IssueDescription: req.IssueDescription,
+		TripAssignmentId: this.id(),
+		TripNo: 1,
+		TimeRangeId: req.TimeRangeId,
+		AssignDateTime: target.Format (returnFormat),
+		AssignTime: req.AssignTime,
+		CustomerId: c.CustomerId,
+	}
+	this.fillCustomer (j)
+	this.jobs = append (this.jobs, j)
+
+	writeData (w, "Jobs", []interface{} { map[string]interface{} {
+		"TicketStatusId": j.TicketStatusId,
+		"TicketId": j.TicketId,
+		"IssueDescription": j.IssueDescription,
+		"TicketStatus": j.TicketStatus,
+		"Customer": c,
+		"Assignments": []interface{} { map[string]interface{} {
+			"TripAssignmentId": j.TripAssignmentId,
+			"TripNo": j.TripNo,
+			"Duration": j.Duration,
+			"TimeRangeId": j.TimeRangeId,
+			"AssignDateTime": j.AssignDateTime,
+			"AssignTime": j.AssignTime,
+		}},
+	}})
+}
+
+func (this *Server) saveSchedule (w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		TicketId, TripAssignmentId, Duration, TimeRangeId, TripNo int
+		AssignDateTime, AssignTime string
+
+		Technicians []struct {
+			EmployeeId int
+			IsSupervisor bool
+		}
+	}
+
+	if err := json.NewDecoder(r.Body).Decode (&req); err != nil {
+		writeJSON (w, http.StatusBadRequest, map[string]interface{} { "Error": err.Error(), "error_description": "invalid json" })
+		return
+	}
+
+	j := this.job (req.TicketId)
+	if j == nil {
+		writeStatus (w, 0, "Job not found")
+		return
+	}
+
+	if j.archived {
+		writeJSON (w, http.StatusGone, map[string]interface{} { "Error": map[string]string { "Message": "Cannot update an archived job" } })
+		return
+	}
+
+	target, err := time.Parse (sendFormat, req.AssignDateTime)
+	if err != nil {
+		writeJSON (w, http.StatusOK, map[string]interface{} {
+			"ApiStatus": apiStatus { Status: 0, Message: "Validation Failed", Errors: map[string]string { "AssignDateTime": "Invalid date" } },
+		})
+		return
+	}
+
+	j.AssignDateTime = target.Format (returnFormat)
+	j.Duration = req.Duration
+	j.TimeRangeId = req.TimeRangeId
+	j.AssignTime = req.AssignTime
+
+	var names, ids []string
+	for _, tech := range req.Technicians {
+		for _, e := range this.employees {
+			if e.EmployeeID == tech.EmployeeId {
+				names = append (names, strings.TrimSpace (e.FirstName + " " + e.LastName))
+			}
+		}
+		ids = append (ids, strconv.Itoa (tech.EmployeeId))
+	}
+	j.Team = strings.Join (names, ", ")
+	j.TeamIds = strings.Join (ids, ",")
+
+	if len(ids) > 0 {
+		j.TicketStatusId = statusScheduled
+		j.TicketStatus = "Scheduled"
+	}
+
+	writeStatus (w, 1, "Success")
+}
+
+func (this *Server) jobSearch (w http.ResponseWriter, r *http.Request) {
+	from, err1 := time.Parse (dateFormat, r.URL.Query().Get ("fromdate"))
+	to, err2 := time.Parse (dateFormat, r.URL.Query().Get ("todate"))
+	if err1 != nil || err2 != nil {
+		writeStatus (w, 0, "Invalid date range")
+		return
+	}
+	to = to.AddDate (0, 0, 1) // inclusive of the last day
+
+	found := make([]*Job, 0)
+	for _, j := range this.jobs {
+		if j.archived { continue }
+
+		at, err := time.Parse (returnFormat, j.AssignDateTime)
+		if err != nil { continue } // not scheduled
+
+		if at.Before (from) == false && at.Before (to) {
+			found = append (found, j)
+		}
+	}
+
+	if len(found) == 0 {
+		writeStatus (w, 0, "No Jobs Found")
+		return
+	}
+
+	writeData (w, "Data", found)
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- FUNCTIONS -------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// starts a new fake server, call Close when you're done with it
+func NewServer () *Server {
+	ret := &Server {
+		companyId: 1,
+		timeZoneName: "Eastern Standard Time",
+		tokens: make(map[string]bool),
+		nextId: 1000,
+		timeRanges: []TimeRange { { "1", "8AM-10AM" }, { "2", "10AM-12PM" }, { "3", "12PM-2PM" }, { "4", "2PM-4PM" } },
+	}
+	ret.Server = httptest.NewServer (ret)
+	return ret
+}
+
+// changes the company info returned when logging in
+func (this *Server) SetCompany (companyId int, timeZoneName string) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	this.companyId = companyId
+	this.timeZoneName = timeZoneName
+}
+
+// returns a valid token without having to log in
+func (this *Server) Token () string {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	return this.newToken()
+}
+
+// makes this token invalid, like it expired
+func (this *Server) RevokeToken (token string) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	delete (this.tokens, token)
+}
+
+// adds an employee, assigning an id if it's not set
+func (this *Server) AddEmployee (e Employee) Employee {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if e.EmployeeID == 0 { e.EmployeeID = this.id() }
+	this.seen (e.EmployeeID)
+
+	this.employees = append (this.employees, &e)
+	return e
+}
+
+// adds a customer, assigning ids if they're not set
+func (this *Server) AddCustomer (c Customer) Customer {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if c.CustomerId == 0 { c.CustomerId = this.id() }
+	this.seen (c.CustomerId)
+
+	// copy the addresses so the caller can't change ours
+	c.Addresses = append ([]Address{}, c.Addresses...)
+	for i := range c.Addresses {
+		if c.Addresses[i].AddressId == 0 { c.Addresses[i].AddressId = this.id() }
+		this.seen (c.Addresses[i].AddressId)
+	}
+
+	this.customers = append (this.customers, &c)
+	return c
+}
+
+// adds a job, assigning ids if they're not set and filling in the customer info
+func (this *Server) AddJob (j Job) Job {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if j.TicketId == 0 { j.TicketId = this.id() }
+	this.seen (j.TicketId)
+
+	if j.TripAssignmentId == 0 { j.TripAssignmentId = this.id() }
+	this.seen (j.TripAssignmentId)
+
+	if j.TripNo == 0 { j.TripNo = 1 }
+	if j.TicketStatusId == 0 {
+		j.TicketStatusId = statusUnassigned
+		j.TicketStatus = "Unassigned"
+	}
+
+	this.fillCustomer (&j)
+	this.jobs = append (this.jobs, &j)
+	return j
+}
+
+// returns the job with this ticket id
+func (this *Server) Job (ticketId int) (Job, bool) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	j := this.job (ticketId)
+	if j == nil { return Job{}, false }
+	return *j, true
+}
+
+// archives the job, after this updates return a 410 and it won't show in searches
+func (this *Server) ArchiveJob (ticketId int) bool {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	j := this.job (ticketId)
+	if j == nil { return false }
+
+	j.archived = true
+	return true
+}
@@ -0,0 +1,71 @@
+
+package swtest
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"testing"
+	"bytes"
+	"encoding/json"
+	"net/http"
+)
+
+// makes a call to the fake server and decodes the response
+func call (t *testing.T, s *Server, method, path, token string, in, out interface{}) int {
+	var body []byte
+	if in != nil {
+		var err error
+		body, err = json.Marshal (in)
+		if err != nil { t.Fatal (err) }
+	}
+
+	req, err := http.NewRequest (method, s.URL + "/api/" + path, bytes.NewBuffer (body))
+	if err != nil { t.Fatal (err) }
+	req.Header.Set ("Token", token)
+
+	resp, err := http.DefaultClient.Do (req)
+	if err != nil { t.Fatal (err) }
+	defer resp.Body.Close()
+
+	if out != nil {
+		if err = json.NewDecoder(resp.Body).Decode (out); err != nil { t.Fatal (err) }
+	}
+	return resp.StatusCode
+}
+
+// the quirks we're trying to reproduce
+func TestServer1 (t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	token := s.Token()
+
+	// bad tokens
+	var status struct { ApiStatus apiStatus }
+	call (t, s, http.MethodGet, "Job/GetTimeRange", "bad", nil, &status)
+	assert.Equal (t, 2, status.ApiStatus.Status)
+	assert.Equal (t, "invalid token", status.ApiStatus.Message)
+
+	// empty searches
+	var customers struct {
+		ApiStatus apiStatus
+		Data struct { Customers []Customer }
+	}
+	call (t, s, http.MethodGet, "Job/GetCustomerSearch?CustomerName=nobody", token, nil, &customers)
+	assert.Equal (t, 1, len(customers.Data.Customers))
+	assert.Equal (t, "No Customer Found", customers.Data.Customers[0].FirstName)
+
+	call (t, s, http.MethodGet, "Job/GetApiJobForSearch?fromdate=11/30/2023&todate=12/01/2023", token, nil, &status)
+	assert.Equal (t, 0, status.ApiStatus.Status)
+	assert.Equal (t, "No Jobs Found", status.ApiStatus.Message)
+
+	// archived jobs
+	c := s.AddCustomer (Customer { FirstName: "Nate", LastName: "Dogg" })
+	j := s.AddJob (Job { CustomerId: c.CustomerId, AssignDateTime: "2023-11-30T10:00:00" })
+	assert.Equal (t, "Nate Dogg", j.CustomerName)
+
+	s.ArchiveJob (j.TicketId)
+
+	code := call (t, s, http.MethodPost, "Job/SaveSchedule", token, map[string]interface{} { "TicketId": j.TicketId, "AssignDateTime": "11/30/2023 10:00:00" }, nil)
+	assert.Equal (t, http.StatusGone, code)
+}
@@ -0,0 +1,154 @@
+/** ****************************************************************************************************************** **
+	ServiceWorks dates and times
+
+	Serviceworks sends times as wall clock strings without a zone, in a few different formats depending on the endpoint,
+	ie "2023-11-30T14:30:00" from searches and "11/30/2023 14:30:00" from others. They're in the company's timezone.
+	Time reads any of them from json, and At puts the wall clock into the company's location.
+	Strings with their own offset, ie "2023-11-30T18:30:00Z", are a real instant, so At converts them instead.
+
+	This package doesn't import serviceworks, so it can be used on its own with Do.
+
+** ****************************************************************************************************************** **/
+
+package swtime
+
+import (
+	"github.com/pkg/errors"
+
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- CONSTS ----------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+const (
+	DateFormat 		= "01/02/2006" // used in query params, ie fromdate for job searches
+	SendFormat 		= "01/02/2006 15:04:00" // what we send when scheduling, serviceworks ignores the seconds
+	ReturnFormat 	= "2006-01-02T15:04:05" // what most endpoints send back
+)
+
+// everything we've seen come back, the ones with a zone are converted, the rest are wall clock
+var formats = []string {
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	ReturnFormat,
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"1/2/2006 15:04:05", // these take leading zeros as well
+	"1/2/2006 15:04",
+	"1/2/2006 3:04:05 PM",
+	"1/2/2006 3:04 PM",
+	"2006-01-02",
+	"1/2/2006",
+}
+
+var ErrInvalidFormat = errors.New("Time isn't in a serviceworks format")
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- STRUCTS ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// a serviceworks time for json, the zero value is an empty string or null
+// the wall clock is kept in UTC until At puts it in the company's location
+// times sent with an offset keep their instant instead
+type Time struct {
+	time.Time
+
+	zoned bool // we were sent an offset, so this is an instant and not a wall clock
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- PRIVATE ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// does the work for Parse, also returning true if the string had its own offset
+func parse (val string, loc *time.Location) (time.Time, bool, error) {
+	if loc == nil { loc = time.UTC }
+
+	val = strings.TrimSpace (val)
+	if len(val) == 0 { return time.Time{}, false, nil }
+
+	for _, f := range formats {
+		if f == time.RFC3339Nano {
+			if t, err := time.Parse (f, val); err == nil { return t.In (loc), true, nil }
+			continue
+		}
+
+		if t, err := time.ParseInLocation (f, val, loc); err == nil { return t, false, nil }
+	}
+	return time.Time{}, false, errors.Wrap (ErrInvalidFormat, val)
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- FUNCTIONS -------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// reads any of our formats, empty strings and null are the zero time
+func (this *Time) UnmarshalJSON (b []byte) error {
+	if bytes.Equal (b, []byte("null")) {
+		this.Time = time.Time{}
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal (b, &s); err != nil { return errors.WithStack (err) }
+
+	t, zoned, err := parse (s, time.UTC)
+	if err != nil { return err }
+
+	this.Time, this.zoned = t, zoned
+	return nil
+}
+
+// writes the wall clock in ReturnFormat, or RFC3339 with the offset if we were sent one, the zero time is an empty string
+func (this Time) MarshalJSON () ([]byte, error) {
+	if this.IsZero() { return []byte(`""`), nil }
+	if this.zoned { return json.Marshal (this.Time.Format (time.RFC3339Nano)) }
+	return json.Marshal (this.Time.Format (ReturnFormat))
+}
+
+// returns the time in the company's location, keeping the wall clock we were sent
+// times sent with an offset keep their instant instead, nil is treated as UTC
+func (this Time) At (loc *time.Location) time.Time {
+	if this.IsZero() { return time.Time{} }
+	if this.zoned {
+		if loc == nil { loc = time.UTC }
+		return this.Time.In (loc)
+	}
+	return Wall (this.Time, loc)
+}
+
+// returns true if we were sent an offset, so this is an instant and not a wall clock
+func (this Time) IsZoned () bool {
+	return this.zoned
+}
+
+// returns a copy moved to the company's location with At, so it keeps whether it's zoned
+func (this Time) InLocation (loc *time.Location) Time {
+	return Time { Time: this.At (loc), zoned: this.zoned }
+}
+
+// returns the same wall clock in loc, not the same instant
+func Wall (t time.Time, loc *time.Location) time.Time {
+	if loc == nil { loc = time.UTC }
+	return time.Date (t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+}
+
+// parses any of the formats serviceworks sends, in the company's location
+// times with their own offset are converted to loc, nil is treated as UTC
+// an empty string is the zero time
+func Parse (val string, loc *time.Location) (time.Time, error) {
+	t, _, err := parse (val, loc)
+	return t, err
+}
+
+// formats the time for scheduling calls like SaveSchedule, in loc
+// nil keeps the time's own location
+func Format (t time.Time, loc *time.Location) string {
+	if loc != nil { t = t.In (loc) }
+	return t.Format (SendFormat)
+}
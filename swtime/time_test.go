@@ -0,0 +1,119 @@
+package swtime
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"testing"
+	"encoding/json"
+	"errors"
+	"time"
+	_ "time/tzdata" // so these run without a zone database installed
+)
+
+// every format we've seen
+func TestParse1 (t *testing.T) {
+	loc, err := time.LoadLocation ("America/Halifax")
+	if err != nil { t.Fatal (err) }
+
+	expected := time.Date (2023, 11, 30, 14, 30, 0, 0, loc)
+
+	for _, val := range []string {
+		"2023-11-30T14:30:00",
+		"2023-11-30T14:30:00.000",
+		"2023-11-30T14:30",
+		"2023-11-30 14:30:00",
+		"11/30/2023 14:30:00",
+		"11/30/2023 14:30",
+		"11/30/2023 2:30:00 PM",
+		"11/30/2023 2:30 PM",
+		"2023-11-30T18:30:00Z", // has its own zone
+		"2023-11-30T14:30:00-04:00",
+	} {
+		at, err := Parse (val, loc)
+		assert.Nil (t, err, val)
+		assert.Equal (t, true, expected.Equal (at), val)
+		assert.Equal (t, loc, at.Location(), val)
+	}
+
+	at, err := Parse ("11/30/2023", loc)
+	assert.Nil (t, err)
+	assert.Equal (t, time.Date (2023, 11, 30, 0, 0, 0, 0, loc), at)
+
+	at, err = Parse ("", loc)
+	assert.Nil (t, err)
+	assert.Equal (t, true, at.IsZero())
+
+	_, err = Parse ("tomorrow", loc)
+	assert.Equal (t, true, errors.Is (err, ErrInvalidFormat))
+}
+
+// reading and writing json
+func TestTime1 (t *testing.T) {
+	loc, err := time.LoadLocation ("America/Halifax")
+	if err != nil { t.Fatal (err) }
+
+	var resp struct {
+		AssignDateTime, CompletionTime, Missing Time
+	}
+
+	err = json.Unmarshal ([]byte(`{"AssignDateTime":"11/30/2023 14:30:00","CompletionTime":"","Missing":null}`), &resp)
+	if err != nil { t.Fatal (err) }
+
+	assert.Equal (t, time.Date (2023, 11, 30, 14, 30, 0, 0, loc), resp.AssignDateTime.At (loc))
+	assert.Equal (t, true, resp.CompletionTime.IsZero())
+	assert.Equal (t, true, resp.Missing.At (loc).IsZero())
+
+	out, err := json.Marshal (resp)
+	assert.Nil (t, err)
+	assert.Equal (t, `{"AssignDateTime":"2023-11-30T14:30:00","CompletionTime":"","Missing":""}`, string(out))
+
+	assert.NotNil (t, json.Unmarshal ([]byte(`{"AssignDateTime":"soon"}`), &resp))
+}
+
+func TestFormat1 (t *testing.T) {
+	loc, err := time.LoadLocation ("America/Halifax")
+	if err != nil { t.Fatal (err) }
+
+	at := time.Date (2023, 11, 30, 18, 30, 45, 0, time.UTC)
+	assert.Equal (t, "11/30/2023 18:30:00", Format (at, nil))
+	assert.Equal (t, "11/30/2023 14:30:00", Format (at, loc))
+
+	assert.Equal (t, time.Date (2023, 11, 30, 18, 30, 45, 0, loc), Wall (at, loc))
+}
+
+// times with their own offset keep their instant, through At and back out to json
+func TestTime2 (t *testing.T) {
+	loc, err := time.LoadLocation ("America/Halifax")
+	if err != nil { t.Fatal (err) }
+
+	expected := time.Date (2023, 11, 30, 14, 30, 0, 0, loc)
+
+	for _, val := range []string { "2023-11-30T18:30:00Z", "2023-11-30T14:30:00-04:00" } {
+		var at Time
+		if err := json.Unmarshal ([]byte(`"` + val + `"`), &at); err != nil { t.Fatal (err) }
+
+		assert.Equal (t, true, at.IsZoned(), val)
+		assert.Equal (t, true, expected.Equal (at.At (loc)), val)
+		assert.Equal (t, loc, at.At (loc).Location(), val)
+
+		// moving it doesn't change the instant, and it's still zoned
+		moved := at.InLocation (loc)
+		assert.Equal (t, true, expected.Equal (moved.At (loc)), val)
+
+		for _, v := range []Time { at, moved } {
+			out, err := json.Marshal (v)
+			assert.Nil (t, err, val)
+
+			var back Time
+			if err := json.Unmarshal (out, &back); err != nil { t.Fatal (err) }
+			assert.Equal (t, true, expected.Equal (back.At (loc)), string(out))
+		}
+	}
+
+	// without one it's still wall clock
+	var wall Time
+	if err := json.Unmarshal ([]byte(`"2023-11-30T14:30:00"`), &wall); err != nil { t.Fatal (err) }
+	assert.Equal (t, false, wall.IsZoned())
+	assert.Equal (t, expected, wall.At (loc))
+	assert.Equal (t, expected, wall.InLocation (loc).At (loc))
+}
@@ -0,0 +1,102 @@
+/** ****************************************************************************************************************** **
+	Company timezones
+
+	Serviceworks gives us the company's timezone as a windows name like "Atlantic Standard Time",
+	which time.LoadLocation can't read. We map it to an IANA location using the CLDR windowsZones table,
+	which is embedded from windowszones.txt. Job times are wall clock in this zone, so getting it right
+	is what keeps scheduling correct around daylight saving changes.
+	The zones come from the system's zone database, programs running without one should import time/tzdata.
+
+** ****************************************************************************************************************** **/
+
+package serviceworks
+
+import (
+	"github.com/pkg/errors"
+
+	"context"
+	_ "embed"
+	"strings"
+	"sync"
+	"time"
+)
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- CONSTS ----------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+//go:embed windowszones.txt
+var windowsZonesData string
+
+var ErrUnknownTimeZone = errors.New("Unknown timezone")
+
+// windows name to IANA name, built from windowsZonesData
+var windowsZones = parseWindowsZones (windowsZonesData)
+
+// so we only load each location once
+var locations sync.Map
+
+type locationKey struct{}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- PRIVATE ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// each line is the windows name and the IANA name separated by a tab, # starts a comment
+func parseWindowsZones (data string) map[string]string {
+	ret := make(map[string]string)
+
+	for _, line := range strings.Split (data, "\n") {
+		line = strings.TrimSpace (line)
+		if len(line) == 0 || strings.HasPrefix (line, "#") { continue }
+
+		parts := strings.SplitN (line, "\t", 2)
+		if len(parts) != 2 { continue }
+
+		ret[strings.ToLower (strings.TrimSpace (parts[0]))] = strings.TrimSpace (parts[1])
+	}
+	return ret
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- FUNCTIONS -------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// returns the IANA name for this windows timezone name, ie "America/Halifax" for "Atlantic Standard Time"
+func IANATimeZone (windowsName string) (string, bool) {
+	name, ok := windowsZones[strings.ToLower (strings.TrimSpace (windowsName))]
+	return name, ok
+}
+
+// loads the location for a windows timezone name
+// IANA names are also accepted, in case serviceworks ever starts sending those
+func LoadLocation (windowsName string) (*time.Location, error) {
+	name, ok := IANATimeZone (windowsName)
+	if ok == false { name = strings.TrimSpace (windowsName) }
+	if len(name) == 0 || strings.EqualFold (name, "Local") { return nil, errors.Wrapf (ErrUnknownTimeZone, "'%s'", windowsName) } // never the server's zone
+
+	if loc, ok := locations.Load (name); ok { return loc.(*time.Location), nil }
+
+	loc, err := time.LoadLocation (name)
+	if err != nil { return nil, errors.Wrapf (ErrUnknownTimeZone, "'%s' : %s", windowsName, err) }
+
+	locations.Store (name, loc)
+	return loc, nil
+}
+
+// returns the company's location from its TimeZoneName
+func (this *RespLogin) Location () (*time.Location, error) {
+	return LoadLocation (this.TimeZoneName)
+}
+
+// returns a context that tells our job calls which location the company is in
+// times we send are converted to it, and times we get back are put in it. Sessions do this for you
+func WithLocation (ctx context.Context, loc *time.Location) context.Context {
+	return context.WithValue (ctx, locationKey{}, loc)
+}
+
+// returns the location from WithLocation, nil if there isn't one
+func locationFrom (ctx context.Context) *time.Location {
+	loc, _ := ctx.Value (locationKey{}).(*time.Location)
+	return loc
+}
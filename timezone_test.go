@@ -0,0 +1,133 @@
+package serviceworks 
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/BeelineRoutes/service-works/swtest"
+	"github.com/BeelineRoutes/service-works/swtime"
+
+	"testing"
+	"context"
+	"errors"
+	"time"
+	_ "time/tzdata" // so these run without a zone database installed
+)
+
+// windows names to locations
+func TestLocation1 (t *testing.T) {
+	name, ok := IANATimeZone ("Atlantic Standard Time")
+	assert.Equal (t, true, ok)
+	assert.Equal (t, "America/Halifax", name)
+
+	for windows, iana := range map[string]string {
+		"Eastern Standard Time": "America/New_York",
+		"central standard time": "America/Chicago", // case doesn't matter
+		"US Mountain Standard Time": "America/Phoenix",
+		"Pacific Standard Time": "America/Los_Angeles",
+		"Hawaiian Standard Time": "Pacific/Honolulu",
+		"UTC": "Etc/UTC",
+	} {
+		name, ok = IANATimeZone (windows)
+		assert.Equal (t, true, ok, windows)
+		assert.Equal (t, iana, name, windows)
+	}
+
+	login := &RespLogin { TimeZoneName: "Atlantic Standard Time" }
+	loc, err := login.Location()
+	if err != nil { t.Fatal (err) }
+	assert.Equal (t, "America/Halifax", loc.String())
+
+	// iana names work too
+	loc, err = LoadLocation ("America/Chicago")
+	assert.Nil (t, err)
+	assert.Equal (t, "America/Chicago", loc.String())
+
+	for _, bad := range []string { "", "Local", "Hogwarts Standard Time" } {
+		_, err = LoadLocation (bad)
+		assert.Equal (t, true, errors.Is (err, ErrUnknownTimeZone), bad)
+	}
+}
+
+// session job times are sent in the company's zone, including across daylight saving
+func TestLocation2 (t *testing.T) {
+	server := swtest.NewServer()
+	defer server.Close()
+
+	server.SetCompany (373, "Atlantic Standard Time")
+	e := server.AddEmployee (swtest.Employee { FirstName: "Harry", LastName: "Potter", IsTechnician: true, IsActive: true })
+	c := server.AddCustomer (swtest.Customer { FirstName: "Nate", LastName: "Dogg" })
+
+	sw := New (WithBaseURL (server.URL))
+	session := sw.Session (sw.TokenSource (swtest.Username, swtest.Password, swtest.ApiKey))
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	loc, err := session.Location (ctx)
+	if err != nil { t.Fatal (err) }
+
+	// before and after the clocks go forward in halifax on march 10th 2024
+	for _, tc := range []struct {
+		target time.Time
+		expected string
+	}{
+		{ time.Date (2024, 3, 10, 5, 30, 0, 0, time.UTC), "2024-03-10T01:30:00" }, // AST, -4
+		{ time.Date (2024, 3, 10, 6, 30, 0, 0, time.UTC), "2024-03-10T03:30:00" }, // ADT, -3
+	} {
+		job, err := session.JobCreate (ctx, "Broken wand", c.CustomerId, 60, 1, tc.target, []int { e.EmployeeID })
+		if err != nil { t.Fatal (err) }
+
+		saved, ok := server.Job (job.TicketId)
+		assert.Equal (t, true, ok)
+		assert.Equal (t, tc.expected, saved.AssignDateTime)
+
+		// and it reads back as the same instant
+		at, err := swtime.Parse (saved.AssignDateTime, loc)
+		assert.Nil (t, err)
+		assert.Equal (t, true, tc.target.Equal (at))
+	}
+}
+
+// searches use the caller's days, and times come back in the company's zone
+func TestLocation3 (t *testing.T) {
+	server := swtest.NewServer()
+	defer server.Close()
+
+	server.SetCompany (373, "Atlantic Standard Time")
+	server.AddJob (swtest.Job { CustomerId: 20147, Duration: 60, IssueDescription: "fix the sink", AssignDateTime: "2024-03-10T10:00:00" })
+	e := server.AddEmployee (swtest.Employee { FirstName: "Harry", LastName: "Potter", IsTechnician: true, IsActive: true })
+	c := server.AddCustomer (swtest.Customer { FirstName: "Nate", LastName: "Dogg" })
+
+	sw := New (WithBaseURL (server.URL))
+	session := sw.Session (sw.TokenSource (swtest.Username, swtest.Password, swtest.ApiKey))
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	loc, err := LoadLocation ("Atlantic Standard Time")
+	if err != nil { t.Fatal (err) }
+
+	// midnight utc is still the 9th in halifax, but the 10th is the day we asked for
+	jobs, err := session.ListJobs (ctx, time.Date (2024, 3, 10, 0, 0, 0, 0, time.UTC), time.Date (2024, 3, 11, 0, 0, 0, 0, time.UTC))
+	if err != nil { t.Fatal (err) }
+
+	if assert.Equal (t, 1, len(jobs)) {
+		assert.Equal (t, loc, jobs[0].AssignDateTime.Location())
+		assert.Equal (t, time.Date (2024, 3, 10, 10, 0, 0, 0, loc), jobs[0].AssignDateTime.Time)
+	}
+
+	// calls with a token use the location from the context
+	target := time.Date (2024, 3, 10, 14, 0, 0, 0, time.UTC)
+	job, err := sw.JobCreate (WithLocation (ctx, loc), server.Token(), "Broken wand", c.CustomerId, 60, 1, target, []int { e.EmployeeID })
+	if err != nil { t.Fatal (err) }
+
+	saved, _ := server.Job (job.TicketId)
+	assert.Equal (t, "2024-03-10T11:00:00", saved.AssignDateTime) // ADT, -3
+	assert.Equal (t, true, target.Equal (job.AssignDateTime.Time))
+
+	// we don't guess at a zone we can't load
+	server.SetCompany (373, "Hogwarts Standard Time")
+	session = sw.Session (sw.TokenSource (swtest.Username, swtest.Password, swtest.ApiKey))
+
+	_, err = session.JobCreate (ctx, "Broken wand", c.CustomerId, 60, 1, target, []int { e.EmployeeID })
+	assert.Equal (t, true, errors.Is (err, ErrUnknownTimeZone))
+}
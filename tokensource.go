@@ -0,0 +1,183 @@
+/** ****************************************************************************************************************** **
+    Token sources
+
+    Hands out tokens so callers don't need to do their own bookkeeping.
+    The login source logs in on first use, refreshes ahead of the 30 day expiry,
+    and logs in again if serviceworks tells us the token is no longer valid.
+
+** ****************************************************************************************************************** **/
+
+package serviceworks
+
+import (
+    "github.com/pkg/errors"
+
+    "context"
+    "sync"
+    "time"
+)
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- INTERFACES ------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+type TokenSource interface {
+    // returns the current login, getting a new one if needed
+    Token (ctx context.Context) (*RespLogin, error)
+
+    // called when serviceworks rejected this token, the next call to Token should not return it
+    Invalidate (token string)
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- STRUCTS ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// always returns the same token, for when you're managing it yourself
+type staticTokenSource struct {
+    login RespLogin
+}
+
+func (this *staticTokenSource) Token (ctx context.Context) (*RespLogin, error) {
+    login := this.login // copy so it can't be changed
+    return &login, nil
+}
+
+func (this *staticTokenSource) Invalidate (token string) {} // nothing we can do about it
+
+// logs in with the username/password and keeps the token fresh
+type LoginTokenSource struct {
+    RefreshBefore time.Duration // how long before expiring we refresh the token, defaults to a day
+
+    // optional, if set we load the saved login for CompanyId on first use and save every new token we get
+    Store TokenStore
+    CompanyId string
+
+    sw *ServiceWorks
+    username, password, apikey string
+
+    lock sync.Mutex
+    login *RespLogin
+    loaded bool // true once we checked the store
+}
+
+// returns the current token, logging in or refreshing as needed
+func (this *LoginTokenSource) Token (ctx context.Context) (*RespLogin, error) {
+    this.lock.Lock()
+    defer this.lock.Unlock()
+
+    if this.loaded == false && this.Store != nil && len(this.CompanyId) > 0 {
+        login, err := this.Store.Load (ctx, this.CompanyId)
+        if err != nil { return nil, err }
+
+        this.login = login // may still be nil if it wasn't saved yet
+    }
+    this.loaded = true
+
+    if this.login == nil { return this.relogin (ctx) } // first time, or it was invalidated
+
+    before := this.RefreshBefore
+    if before <= 0 { before = time.Hour * 24 }
+
+    if time.Now().Add(before).After (this.login.ExpiresAt()) {
+        // getting close to expiring, so refresh it
+        login, err := this.sw.RefreshToken (ctx, this.login.Token)
+        if err != nil {
+            if isTokenErr (err) { return this.relogin (ctx) } // token is already dead, start over
+            return nil, err
+        }
+        
+        this.login = login
+        if err = this.save (ctx); err != nil { return nil, err }
+    }
+
+    login := *this.login // copy so it can't be changed
+    return &login, nil
+}
+
+// forgets the token if it's the one we're currently handing out
+func (this *LoginTokenSource) Invalidate (token string) {
+    this.lock.Lock()
+    defer this.lock.Unlock()
+
+    if this.login != nil && this.login.Token == token {
+        if this.Store != nil {
+            this.Store.Delete (context.Background(), this.login.CompanyId) // best effort, it's dead either way
+        }
+        this.login = nil // next call to Token will log in again
+    }
+}
+
+// writes the current login to our store, expects the lock to be held
+func (this *LoginTokenSource) save (ctx context.Context) error {
+    if this.Store == nil { return nil }
+
+    err := this.Store.Save (ctx, this.login)
+    return errors.Wrap (err, "saving token") // we keep the login in memory, so the next call still works
+}
+
+// logs in from scratch, expects the lock to be held
+func (this *LoginTokenSource) relogin (ctx context.Context) (*RespLogin, error) {
+    login, err := this.sw.Login (ctx, this.username, this.password, this.apikey)
+    if err != nil { return nil, err }
+
+    this.login = login
+    if err = this.save (ctx); err != nil { return nil, err }
+
+    ret := *login
+    return &ret, nil
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- PRIVATE ---------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// returns true if this error means we need a new token
+func isTokenErr (err error) bool {
+    switch errors.Cause (err) {
+    case ErrInvalidCode, ErrAuthExpired:
+        return true
+    }
+    return false
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- FUNCTIONS -------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+// wraps a token you already have in a TokenSource
+func StaticTokenSource (token string) TokenSource {
+    return &staticTokenSource { login: RespLogin { Token: token } }
+}
+
+// creates a token source that logs in with these credentials
+func (this *ServiceWorks) TokenSource (username, password, apikey string) *LoginTokenSource {
+    return &LoginTokenSource {
+        sw: this,
+        username: username,
+        password: password,
+        apikey: apikey,
+    }
+}
+
+// calls fn with a token from the source
+// if serviceworks rejects the token we invalidate it, get a new one and replay the call once
+func UseToken (ctx context.Context, ts TokenSource, fn func (token string) error) error {
+    login, err := ts.Token (ctx)
+    if err != nil { return err }
+
+    return useLogin (ctx, ts, login, fn)
+}
+
+// like UseToken, starting with a login we already got from the source
+func useLogin (ctx context.Context, ts TokenSource, login *RespLogin, fn func (token string) error) (err error) {
+    err = fn (login.Token)
+    if err == nil || isTokenErr (err) == false { return err } // worked, or failed for some other reason
+
+    ts.Invalidate (login.Token)
+
+    login, err = ts.Token (ctx)
+    if err != nil { return err }
+
+    return fn (login.Token) // one more try
+}
@@ -0,0 +1,114 @@
+
+package serviceworks 
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/pkg/errors"
+
+	"testing"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+// counts logins and refreshes, and only accepts the most recent token
+type loginServer struct {
+	logins, refreshes int 
+	token string 
+}
+
+func (this *loginServer) ServeHTTP (w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/api/Login/LoginWithKey":
+		this.logins++
+		this.token = fmt.Sprintf("login-%d", this.logins)
+		fmt.Fprintf (w, `{"Status":1,"Companyid":373,"Token":"%s","TimeZoneName":"Atlantic Standard Time"}`, this.token)
+
+	case "/api/Login/RefreshToken":
+		this.refreshes++
+		this.token = fmt.Sprintf("refresh-%d", this.refreshes)
+		fmt.Fprintf (w, `{"Status":1,"Companyid":373,"Token":"%s","TimeZoneName":"Atlantic Standard Time"}`, this.token)
+
+	default:
+		if r.Header.Get ("Token") != this.token {
+			w.Write ([]byte(`{"ApiStatus":{"Status":2,"Message":"invalid token"}}`))
+			return 
+		}
+		w.Write ([]byte(`{"ApiStatus":{"Status":1},"Data":{"EmployeeList":[{"EmployeeID":1694}]}}`))
+	}
+}
+
+// logs in once, then reuses the token
+func TestTokenSource1 (t *testing.T) {
+	fake := &loginServer{}
+	server := httptest.NewServer (fake)
+	defer server.Close()
+
+	sw := New (WithBaseURL (server.URL))
+	ts := sw.TokenSource ("user", "pass", "key")
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	login, err := ts.Token (ctx)
+	if err != nil { t.Fatal (err) }
+
+	assert.Equal (t, "login-1", login.Token)
+	assert.Equal (t, "373", login.CompanyId)
+	assert.Equal (t, false, login.IssuedAt.IsZero())
+	assert.Equal (t, login.IssuedAt.AddDate(0, 0, 29), login.ExpiresAt())
+
+	login, err = ts.Token (ctx)
+	if err != nil { t.Fatal (err) }
+
+	assert.Equal (t, "login-1", login.Token)
+	assert.Equal (t, 1, fake.logins)
+
+	// now pretend it's about to expire
+	ts.RefreshBefore = time.Hour * 24 * 30
+	login, err = ts.Token (ctx)
+	if err != nil { t.Fatal (err) }
+
+	assert.Equal (t, "refresh-1", login.Token)
+	assert.Equal (t, 1, fake.logins)
+}
+
+// a rejected token means we log in again and replay the call
+func TestTokenSource2 (t *testing.T) {
+	fake := &loginServer{}
+	server := httptest.NewServer (fake)
+	defer server.Close()
+
+	sw := New (WithBaseURL (server.URL))
+	ts := sw.TokenSource ("user", "pass", "key")
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	_, err := ts.Token (ctx)
+	if err != nil { t.Fatal (err) }
+
+	fake.token = "someone-else" // our token is no longer good
+
+	var crew []*Employee 
+	calls := 0
+	err = UseToken (ctx, ts, func (token string) (err error) {
+		calls++
+		crew, err = sw.CrewList (ctx, token)
+		return 
+	})
+	if err != nil { t.Fatal (err) }
+
+	assert.Equal (t, 2, calls)
+	assert.Equal (t, 2, fake.logins)
+	assert.Equal (t, 1694, crew[0].EmployeeID)
+
+	// a static source can't get a new token, so we get the error back
+	err = UseToken (ctx, StaticTokenSource ("bad"), func (token string) (err error) {
+		_, err = sw.CrewList (ctx, token)
+		return 
+	})
+	assert.Equal (t, ErrInvalidCode, errors.Cause(err))
+}
@@ -0,0 +1,182 @@
+/** ****************************************************************************************************************** **
+    Token stores
+
+    Persists logins between restarts so we don't have to log in again for every company.
+    Logins are keyed by the company id from RespLogin.
+    The file store writes one file per company, atomically, and can encrypt them with AES-GCM.
+
+** ****************************************************************************************************************** **/
+
+package serviceworks
+
+import (
+    "github.com/pkg/errors"
+
+    "context"
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/rand"
+    "encoding/json"
+    "io"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sync"
+)
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- INTERFACES ------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+type TokenStore interface {
+    // returns the saved login for this company, or nil if we don't have one
+    Load (ctx context.Context, companyId string) (*RespLogin, error)
+
+    // saves the login, keyed by its CompanyId
+    Save (ctx context.Context, login *RespLogin) error
+
+    // removes the login for this company, not an error if it doesn't exist
+    Delete (ctx context.Context, companyId string) error
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- MEMORY ----------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+type MemoryTokenStore struct {
+    lock sync.RWMutex
+    logins map[string]RespLogin
+}
+
+func (this *MemoryTokenStore) Load (ctx context.Context, companyId string) (*RespLogin, error) {
+    this.lock.RLock()
+    defer this.lock.RUnlock()
+
+    login, ok := this.logins[companyId]
+    if ok == false { return nil, nil } // not found
+    return &login, nil
+}
+
+func (this *MemoryTokenStore) Save (ctx context.Context, login *RespLogin) error {
+    if login == nil || len(login.CompanyId) == 0 { return errors.Errorf ("login is missing a company id") }
+
+    this.lock.Lock()
+    defer this.lock.Unlock()
+
+    if this.logins == nil { this.logins = make(map[string]RespLogin) }
+    this.logins[login.CompanyId] = *login
+    return nil
+}
+
+func (this *MemoryTokenStore) Delete (ctx context.Context, companyId string) error {
+    this.lock.Lock()
+    defer this.lock.Unlock()
+
+    delete (this.logins, companyId)
+    return nil
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- FILE ------------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+var validCompanyId = regexp.MustCompile(`^[A-Za-z0-9_\-]+$`) // so a company id can't walk out of our directory
+
+type FileTokenStore struct {
+    dir string
+    aead cipher.AEAD // nil if we're not encrypting
+}
+
+func (this *FileTokenStore) path (companyId string) (string, error) {
+    if validCompanyId.MatchString (companyId) == false { return "", errors.Errorf ("invalid company id '%s'", companyId) }
+    return filepath.Join (this.dir, companyId + ".json"), nil
+}
+
+func (this *FileTokenStore) Load (ctx context.Context, companyId string) (*RespLogin, error) {
+    fileName, err := this.path (companyId)
+    if err != nil { return nil, err }
+
+    data, err := os.ReadFile (fileName)
+    if os.IsNotExist (err) { return nil, nil } // not found
+    if err != nil { return nil, errors.WithStack (err) }
+
+    if this.aead != nil {
+        size := this.aead.NonceSize()
+        if len(data) < size { return nil, errors.Errorf ("token file for %s is too short", companyId) }
+
+        data, err = this.aead.Open (nil, data[:size], data[size:], []byte(companyId))
+        if err != nil { return nil, errors.Wrapf (err, "decrypting token for %s", companyId) }
+    }
+
+    login := &RespLogin{}
+    err = json.Unmarshal (data, login)
+    if err != nil { return nil, errors.Wrapf (err, "token file for %s", companyId) }
+
+    return login, nil
+}
+
+func (this *FileTokenStore) Save (ctx context.Context, login *RespLogin) error {
+    if login == nil { return errors.Errorf ("login is missing a company id") }
+
+    fileName, err := this.path (login.CompanyId)
+    if err != nil { return err }
+
+    data, err := json.Marshal (login)
+    if err != nil { return errors.WithStack (err) }
+
+    if this.aead != nil {
+        nonce := make([]byte, this.aead.NonceSize())
+        if _, err := io.ReadFull (rand.Reader, nonce); err != nil { return errors.WithStack (err) }
+
+        // company id is the additional data, so a file can't be swapped for another company's
+        data = this.aead.Seal (nonce, nonce, data, []byte(login.CompanyId))
+    }
+
+    // write to a temp file and rename it, so a crash never leaves a half written token
+    tmp, err := os.CreateTemp (this.dir, login.CompanyId + ".*.tmp")
+    if err != nil { return errors.WithStack (err) }
+    defer os.Remove (tmp.Name()) // cleans up if we fail, does nothing after the rename
+
+    _, err = tmp.Write (data)
+    if err == nil { err = tmp.Sync() }
+    if cerr := tmp.Close(); err == nil { err = cerr }
+    if err != nil { return errors.WithStack (err) }
+
+    return errors.WithStack (os.Rename (tmp.Name(), fileName))
+}
+
+func (this *FileTokenStore) Delete (ctx context.Context, companyId string) error {
+    fileName, err := this.path (companyId)
+    if err != nil { return err }
+
+    err = os.Remove (fileName)
+    if os.IsNotExist (err) { return nil } // already gone
+    return errors.WithStack (err)
+}
+
+  //-----------------------------------------------------------------------------------------------------------------------//
+ //----- FUNCTIONS -------------------------------------------------------------------------------------------------------//
+//-----------------------------------------------------------------------------------------------------------------------//
+
+func NewMemoryTokenStore () *MemoryTokenStore {
+    return &MemoryTokenStore { logins: make(map[string]RespLogin) }
+}
+
+// stores logins as files in this directory, creating it if needed
+// if key is set the files are encrypted with AES-GCM, it needs to be 16, 24 or 32 bytes long
+func NewFileTokenStore (dir string, key []byte) (*FileTokenStore, error) {
+    err := os.MkdirAll (dir, 0700)
+    if err != nil { return nil, errors.WithStack (err) }
+
+    ret := &FileTokenStore { dir: dir }
+
+    if len(key) > 0 {
+        block, err := aes.NewCipher (key)
+        if err != nil { return nil, errors.WithStack (err) }
+
+        ret.aead, err = cipher.NewGCM (block)
+        if err != nil { return nil, errors.WithStack (err) }
+    }
+
+    return ret, nil
+}
@@ -0,0 +1,105 @@
+
+package serviceworks 
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"testing"
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// saves, loads and deletes from each type of store
+func TestTokenStore1 (t *testing.T) {
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	dir := t.TempDir()
+
+	plain, err := NewFileTokenStore (filepath.Join (dir, "plain"), nil)
+	if err != nil { t.Fatal (err) }
+
+	secret, err := NewFileTokenStore (filepath.Join (dir, "secret"), []byte("0123456789abcdef0123456789abcdef"))
+	if err != nil { t.Fatal (err) }
+
+	login := &RespLogin { Token: "blah#Pink", CompanyId: "373", TimeZoneName: "Atlantic Standard Time", IssuedAt: time.Now().Round(0) }
+
+	for _, store := range []TokenStore { NewMemoryTokenStore(), plain, secret } {
+		found, err := store.Load (ctx, "373")
+		if err != nil { t.Fatal (err) }
+		assert.Nil (t, found)
+
+		err = store.Save (ctx, login)
+		if err != nil { t.Fatal (err) }
+
+		found, err = store.Load (ctx, "373")
+		if err != nil { t.Fatal (err) }
+
+		assert.Equal (t, login.Token, found.Token)
+		assert.Equal (t, true, login.IssuedAt.Equal (found.IssuedAt))
+
+		err = store.Delete (ctx, "373")
+		if err != nil { t.Fatal (err) }
+
+		found, err = store.Load (ctx, "373")
+		if err != nil { t.Fatal (err) }
+		assert.Nil (t, found)
+	}
+
+	// make sure the encrypted one isn't readable, and can't be opened with a different key
+	err = secret.Save (ctx, login)
+	if err != nil { t.Fatal (err) }
+
+	data, err := os.ReadFile (filepath.Join (dir, "secret", "373.json"))
+	if err != nil { t.Fatal (err) }
+	assert.Equal (t, false, strings.Contains (string(data), "blah#Pink"))
+
+	other, err := NewFileTokenStore (filepath.Join (dir, "secret"), []byte("fedcba9876543210fedcba9876543210"))
+	if err != nil { t.Fatal (err) }
+
+	_, err = other.Load (ctx, "373")
+	assert.NotNil (t, err)
+
+	// and we don't allow paths as company ids
+	_, err = plain.Load (ctx, "../373")
+	assert.NotNil (t, err)
+}
+
+// the token source uses the store instead of logging in
+func TestTokenStore2 (t *testing.T) {
+	fake := &loginServer { token: "saved" }
+	server := httptest.NewServer (fake)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout (context.Background(), time.Minute) // this should take < 1 minute
+	defer cancel()
+
+	store := NewMemoryTokenStore()
+	store.Save (ctx, &RespLogin { Token: "saved", CompanyId: "373", IssuedAt: time.Now() })
+
+	sw := New (WithBaseURL (server.URL))
+	ts := sw.TokenSource ("user", "pass", "key")
+	ts.Store = store
+	ts.CompanyId = "373"
+
+	login, err := ts.Token (ctx)
+	if err != nil { t.Fatal (err) }
+
+	assert.Equal (t, "saved", login.Token)
+	assert.Equal (t, 0, fake.logins)
+
+	// once it's rejected we log in and save the new one
+	ts.Invalidate ("saved")
+
+	login, err = ts.Token (ctx)
+	if err != nil { t.Fatal (err) }
+	assert.Equal (t, "login-1", login.Token)
+
+	saved, err := store.Load (ctx, "373")
+	if err != nil { t.Fatal (err) }
+	assert.Equal (t, "login-1", saved.Token)
+}